@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/reader"
+	"github.com/Zekfad/hd-tool/hash_db"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [a] [b]",
+	Short: "Compare game archives or directories",
+	Long: `Compare two archives or two directories of archives (e.g. game builds).
+
+Files are matched by name and type, and their inline, stream and GPU buffers are
+compared by content hash. Changes are reported as:
+  added     file exists only in b
+  removed   file exists only in a
+  modified  file buffers differ, changed buffers are listed
+  moved     file is contained in other packages (directory mode only)`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		a := args[0]
+		b := args[1]
+
+		format, err := formatFromFlags(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		options, err := scanOptionsFromFlags(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		dbName, err := cmd.Flags().GetString("hash-db")
+		if err != nil {
+			fmt.Println("Failed to parse hash db flag")
+			return
+		}
+
+		var db = hash_db.HashDB{}
+		if dbName != "" {
+			db, err = hash_db.FromFile(dbName, false)
+			if err != nil {
+				fmt.Printf("Failed load hash db %s\n", err)
+				return
+			}
+		}
+
+		directories, err := sameKindPaths(a, b)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		var summary scanSummary
+		before, err := indexResources(a, options, &summary)
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+		after, err := indexResources(b, options, &summary)
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+		if directories {
+			// keep stdout clean for scripts
+			summary.Print(os.Stderr)
+		}
+
+		changes := diffResources(before, after, db)
+		if format == formatJson {
+			err = writeJson(os.Stdout, changes)
+		} else {
+			err = writeRecords(os.Stdout, format, changes)
+		}
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+	},
+}
+
+const (
+	changeAdded    = "added"
+	changeRemoved  = "removed"
+	changeModified = "modified"
+	changeMoved    = "moved"
+)
+
+type resourceChange struct {
+	Change   string
+	Name     string
+	NameHash string
+	Type     string
+	TypeHash string
+	// changed buffers: inline, stream or gpu
+	Buffers []string `json:",omitempty"`
+	// packages containing file in a and b
+	From []string `json:",omitempty"`
+	To   []string `json:",omitempty"`
+}
+
+type resourceKey struct {
+	Name game_data.NameHash
+	Type game_data.TypeHash
+}
+
+type digest = [sha256.Size]byte
+
+// resourceState collects every copy of a file, as the same file can be stored
+// in several packages.
+type resourceState struct {
+	packages map[string]bool
+	inline   map[digest]bool
+	stream   map[digest]bool
+	gpu      map[digest]bool
+}
+
+type resourceIndex map[resourceKey]*resourceState
+
+// sameKindPaths reports whether both paths are directories, or fails if only
+// one of them is.
+func sameKindPaths(a string, b string) (bool, error) {
+	statA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	statB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if statA.IsDir() != statB.IsDir() {
+		return false, fmt.Errorf("can't compare archive with directory")
+	}
+	return statA.IsDir(), nil
+}
+
+// indexResources hashes files of an archive or of all archives in directory.
+// Package names are paths relative to directory, and empty for single archive.
+func indexResources(name string, options reader.ScanOptions, summary *scanSummary) (resourceIndex, error) {
+	stat, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	index := resourceIndex{}
+	if !stat.IsDir() {
+		archive, err := reader.OpenArchive(name)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+		if err := index.add("", archive); err != nil {
+			return nil, err
+		}
+		return index, nil
+	}
+
+	for result := range reader.ArchivesFromDirectory(name, options) {
+		if !summary.add(result) {
+			continue
+		}
+		pkg, err := filepath.Rel(name, result.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := index.add(filepath.ToSlash(pkg), result.Archive); err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+func (index resourceIndex) add(pkg string, archive game_data.Archive) error {
+	for _, file := range archive.GetFiles() {
+		inline, err := game_data.ReadInlineBuffer(file)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to read inline buffer of %016X.%s", file.GetName(), file.GetType()), err)
+		}
+		stream, err := game_data.ReadStreamBuffer(file)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to read stream buffer of %016X.%s", file.GetName(), file.GetType()), err)
+		}
+		gpu, err := game_data.ReadGpuBuffer(file)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to read gpu buffer of %016X.%s", file.GetName(), file.GetType()), err)
+		}
+
+		key := resourceKey{file.GetName(), file.GetType()}
+		state, ok := index[key]
+		if !ok {
+			state = &resourceState{
+				packages: map[string]bool{},
+				inline:   map[digest]bool{},
+				stream:   map[digest]bool{},
+				gpu:      map[digest]bool{},
+			}
+			index[key] = state
+		}
+		state.packages[pkg] = true
+		state.inline[sha256.Sum256(inline)] = true
+		state.stream[sha256.Sum256(stream)] = true
+		state.gpu[sha256.Sum256(gpu)] = true
+	}
+	return nil
+}
+
+func diffResources(before resourceIndex, after resourceIndex, db hash_db.HashDB) []resourceChange {
+	var changes []resourceChange
+	change := func(kind string, key resourceKey) resourceChange {
+		return resourceChange{
+			Change:   kind,
+			Name:     fileName(key.Name, db),
+			NameHash: fileName(key.Name, nil),
+			Type:     typeName(key.Type, db),
+			TypeHash: fmt.Sprintf("%016X", uint64(key.Type)),
+		}
+	}
+
+	for key, old := range before {
+		new, ok := after[key]
+		if !ok {
+			removed := change(changeRemoved, key)
+			removed.From = sortedKeys(old.packages)
+			changes = append(changes, removed)
+			continue
+		}
+
+		var buffers []string
+		if !maps.Equal(old.inline, new.inline) {
+			buffers = append(buffers, "inline")
+		}
+		if !maps.Equal(old.stream, new.stream) {
+			buffers = append(buffers, "stream")
+		}
+		if !maps.Equal(old.gpu, new.gpu) {
+			buffers = append(buffers, "gpu")
+		}
+		if len(buffers) > 0 {
+			modified := change(changeModified, key)
+			modified.Buffers = buffers
+			changes = append(changes, modified)
+		}
+
+		if !maps.Equal(old.packages, new.packages) {
+			moved := change(changeMoved, key)
+			moved.From = sortedKeys(old.packages)
+			moved.To = sortedKeys(new.packages)
+			changes = append(changes, moved)
+		}
+	}
+	for key, new := range after {
+		if _, ok := before[key]; !ok {
+			added := change(changeAdded, key)
+			added.To = sortedKeys(new.packages)
+			changes = append(changes, added)
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b resourceChange) int {
+		return cmp.Or(
+			cmp.Compare(a.Change, b.Change),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.NameHash, b.NameHash),
+			cmp.Compare(a.TypeHash, b.TypeHash),
+		)
+	})
+	return changes
+}
+
+// sortedKeys returns sorted package names, single archive has no packages.
+func sortedKeys(packages map[string]bool) []string {
+	keys := slices.Sorted(maps.Keys(packages))
+	if len(keys) == 1 && keys[0] == "" {
+		return nil
+	}
+	return keys
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().String("hash-db", "", "Hash DB file.")
+	addFormatFlag(diffCmd)
+	addScanFlags(diffCmd)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/glob"
+	"github.com/Zekfad/hd-tool/hash_db"
+	"github.com/spf13/cobra"
+)
+
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("type", nil, "only unpack files of given types (type names or hex type hashes)")
+	cmd.Flags().StringSlice("name", nil, "only unpack files with name matching glob, with or without type extension (\"**\" matches any directories)")
+	cmd.Flags().String("hashes", "", "only unpack files with name hash listed in Hash DB Target file")
+}
+
+// fileFilter selects archive files to unpack. File must match every set
+// filter, zero filter selects all files.
+type fileFilter struct {
+	// any of types
+	Types []game_data.TypeHash
+	// glob patterns matched against resolved names, see [glob.Match]
+	Names []string
+	// name hashes
+	Hashes hash_db.HashDBTarget
+}
+
+func filterFromFlags(cmd *cobra.Command) (fileFilter, error) {
+	types, err := cmd.Flags().GetStringSlice("type")
+	if err != nil {
+		return fileFilter{}, fmt.Errorf("failed to parse type flag")
+	}
+	names, err := cmd.Flags().GetStringSlice("name")
+	if err != nil {
+		return fileFilter{}, fmt.Errorf("failed to parse name flag")
+	}
+	hashesName, err := cmd.Flags().GetString("hashes")
+	if err != nil {
+		return fileFilter{}, fmt.Errorf("failed to parse hashes flag")
+	}
+
+	var filter fileFilter
+	for _, value := range types {
+		_type, err := game_data.ParseTypeHash(value)
+		if err != nil {
+			return fileFilter{}, err
+		}
+		filter.Types = append(filter.Types, _type)
+	}
+	for _, pattern := range names {
+		if err := glob.Validate(pattern); err != nil {
+			return fileFilter{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	filter.Names = names
+	if hashesName != "" {
+		filter.Hashes, err = hash_db.TargetFromFile(hashesName)
+		if err != nil {
+			return fileFilter{}, errors.Join(
+				fmt.Errorf("failed to load hashes"),
+				err,
+			)
+		}
+	}
+	return filter, nil
+}
+
+// IsSet reports whether any filter is set.
+func (filter fileFilter) IsSet() bool {
+	return len(filter.Types) > 0 || len(filter.Names) > 0 || filter.Hashes != nil
+}
+
+// Match reports whether file is selected, names are resolved with db.
+func (filter fileFilter) Match(file game_data.File, db hash_db.HashDB) bool {
+	if len(filter.Types) > 0 && !slices.Contains(filter.Types, file.GetType()) {
+		return false
+	}
+	if filter.Hashes != nil && !filter.Hashes[file.GetName()] {
+		return false
+	}
+	if len(filter.Names) > 0 {
+		name := fileName(file.GetName(), db)
+		// patterns are validated
+		matched, _ := glob.MatchAny(filter.Names, name)
+		if !matched {
+			matched, _ = glob.MatchAny(filter.Names, name+"."+typeName(file.GetType(), db))
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
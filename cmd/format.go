@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	formatTable = "table"
+	formatCsv   = "csv"
+	formatJson  = "json"
+)
+
+func addFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().String("format", formatTable, "output format: json, csv or table")
+}
+
+func formatFromFlags(cmd *cobra.Command) (string, error) {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return "", fmt.Errorf("failed to parse format flag")
+	}
+	switch format {
+	case formatTable, formatCsv, formatJson:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected json, csv or table", format)
+	}
+}
+
+func writeJson(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeRecords writes structs as csv or table rows, struct fields are columns.
+func writeRecords[T any](w io.Writer, format string, records []T) error {
+	recordType := reflect.TypeFor[T]()
+	header := make([]string, recordType.NumField())
+	for i := range header {
+		header[i] = recordType.Field(i).Name
+	}
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		value := reflect.ValueOf(record)
+		rows[i] = make([]string, len(header))
+		for j := range header {
+			switch field := value.Field(j).Interface().(type) {
+			case []string:
+				rows[i][j] = strings.Join(field, " ")
+			default:
+				rows[i][j] = fmt.Sprint(field)
+			}
+		}
+	}
+
+	switch format {
+	case formatCsv:
+		writer := csv.NewWriter(w)
+		writer.Write(header)
+		writer.WriteAll(rows)
+		return writer.Error()
+	case formatTable:
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, row := range append([][]string{header}, rows...) {
+			for j, cell := range row {
+				if j > 0 {
+					fmt.Fprint(writer, "\t")
+				}
+				fmt.Fprint(writer, cell)
+			}
+			fmt.Fprintln(writer)
+		}
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unsupported records format %q", format)
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strconv"
 
@@ -35,6 +36,12 @@ var targetCmd = &cobra.Command{
 			return
 		}
 
+		options, err := scanOptionsFromFlags(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
 		if !(includePackageName || includeTypeName || includeFileName) {
 			fmt.Println("No work to be done, flags are missing.")
 			return
@@ -42,9 +49,13 @@ var targetCmd = &cobra.Command{
 
 		target := hash_db.HashDBTarget{}
 
-		for fullPath, archive := range reader.ArchivesFromDirectory(dirname) {
+		var summary scanSummary
+		for result := range reader.ArchivesFromDirectory(dirname, options) {
+			if !summary.add(result) {
+				continue
+			}
 			if includePackageName {
-				filename := filepath.Base(fullPath)
+				filename := filepath.Base(result.Path)
 				packageHash, err := strconv.ParseUint(filename, 16, 64)
 				if err != nil {
 					fmt.Printf("Warn: Package %s has non-hash name.", filename)
@@ -53,7 +64,7 @@ var targetCmd = &cobra.Command{
 				}
 			}
 
-			for _, file := range archive.GetFiles() {
+			for _, file := range result.Archive.GetFiles() {
 				if includeTypeName {
 					target[uint64(file.GetType())] = true
 				}
@@ -62,6 +73,7 @@ var targetCmd = &cobra.Command{
 				}
 			}
 		}
+		summary.Print(os.Stdout)
 
 		err = target.SaveToFile(targetName, true)
 		if err != nil {
@@ -98,4 +110,5 @@ func init() {
 	targetCmd.Flags().Bool("package", false, "include package names")
 	targetCmd.Flags().Bool("type", false, "include type names")
 	targetCmd.Flags().Bool("file", false, "include file names")
+	addScanFlags(targetCmd)
 }
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd1"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
+	"github.com/Zekfad/hd-tool/game_data/reader"
+	"github.com/spf13/cobra"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info [archive]",
+	Short: "Show game archive header",
+	Long: `Show HD1 or HD2 archive header fields, checksum and type table.
+
+HD1 type table has no alignments, counts are calculated from file table.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		archiveName := args[0]
+
+		format, err := formatFromFlags(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		archive, err := reader.OpenArchive(archiveName)
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+		defer archive.Close()
+
+		info, err := describeArchive(archive.Archive)
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+
+		if format == formatJson {
+			err = writeJson(os.Stdout, info)
+		} else {
+			err = writeInfoRecords(info, format)
+		}
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+	},
+}
+
+type archiveInfo struct {
+	Version  string
+	Checksum uint32
+	// hd1HeaderInfo or hd2.ArchiveHeader
+	Header any
+	Types  []typeEntry
+}
+
+// hd1HeaderInfo joins headers of packed and unpacked HD1 archive.
+type hd1HeaderInfo struct {
+	ArchiveVersion game_data.ArchiveVersion
+	ByteOrder      string
+	UnpackedSize   uint32
+	Reserved       uint32
+	ChunksCount    uint32
+	EntriesCount   uint32
+	Magic          string
+}
+
+type typeEntry struct {
+	Type         string
+	TypeHash     string
+	Count        uint32
+	Alignment    uint32
+	GpuAlignment uint32
+}
+
+type headerField struct {
+	Field string
+	Value string
+}
+
+func describeArchive(archive game_data.Archive) (archiveInfo, error) {
+	info := archiveInfo{
+		Version:  fmt.Sprintf("%#X", uint32(archive.GetVersion())),
+		Checksum: archive.GetChecksum(),
+	}
+	switch archive := archive.(type) {
+	case *hd1.Archive:
+		info.Header = hd1HeaderInfo{
+			ArchiveVersion: archive.ArchiveVersion,
+			ByteOrder:      archive.GetByteOrder().String(),
+			UnpackedSize:   archive.UnpackedSize,
+			Reserved:       archive.Reserved,
+			ChunksCount:    uint32(len(archive.Chunks)),
+			EntriesCount:   archive.Unpacked.Header.EntriesCount,
+			Magic:          hex.EncodeToString(archive.Unpacked.Header.Magic),
+		}
+		for _, _type := range archive.Unpacked.Types {
+			var count uint32
+			for _, file := range archive.Unpacked.Files {
+				if file.Type == _type.Type {
+					count++
+				}
+			}
+			info.Types = append(info.Types, newTypeEntry(_type.Type, count, 0, 0))
+		}
+	case *hd2.Archive:
+		info.Header = archive.Header
+		for _, _type := range archive.Types {
+			info.Types = append(info.Types, newTypeEntry(_type.Type, _type.Count, _type.Alignment, _type.GpuAlignment))
+		}
+	default:
+		return archiveInfo{}, fmt.Errorf("unsupported archive type %T", archive)
+	}
+	return info, nil
+}
+
+func newTypeEntry(_type game_data.TypeHash, count uint32, alignment uint32, gpuAlignment uint32) typeEntry {
+	return typeEntry{
+		Type:         typeName(_type, nil),
+		TypeHash:     fmt.Sprintf("%016X", uint64(_type)),
+		Count:        count,
+		Alignment:    alignment,
+		GpuAlignment: gpuAlignment,
+	}
+}
+
+// writeInfoRecords writes header fields and type table as two csv or table
+// sections separated by an empty line.
+func writeInfoRecords(info archiveInfo, format string) error {
+	fields := []headerField{
+		{"Version", info.Version},
+		{"Checksum", fmt.Sprintf("%#08X", info.Checksum)},
+	}
+	header := reflect.ValueOf(info.Header)
+	for i := range header.NumField() {
+		switch name := header.Type().Field(i).Name; name {
+		case "ArchiveVersion", "Checksum":
+		default:
+			fields = append(fields, headerField{name, fmt.Sprint(header.Field(i).Interface())})
+		}
+	}
+	if err := writeRecords(os.Stdout, format, fields); err != nil {
+		return err
+	}
+	fmt.Println()
+	return writeRecords(os.Stdout, format, info.Types)
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+	addFormatFlag(infoCmd)
+}
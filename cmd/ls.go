@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd1"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
+	"github.com/Zekfad/hd-tool/game_data/reader"
+	"github.com/Zekfad/hd-tool/hash_db"
+	"github.com/spf13/cobra"
+)
+
+var lsCmd = &cobra.Command{
+	Use:   "ls [archive]",
+	Short: "List files in game archive",
+	Long: `List files in HD1 or HD2 archive without unpacking it.
+
+Index is file index from HD2 file table, or file position for HD1 archives.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		archiveName := args[0]
+
+		format, err := formatFromFlags(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		dbName, err := cmd.Flags().GetString("hash-db")
+		if err != nil {
+			fmt.Println("Failed to parse hash db flag")
+			return
+		}
+
+		var db = hash_db.HashDB{}
+		if dbName != "" {
+			db, err = hash_db.FromFile(dbName, false)
+			if err != nil {
+				fmt.Printf("Failed load hash db %s\n", err)
+				return
+			}
+		}
+
+		archive, err := reader.OpenArchive(archiveName)
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+		defer archive.Close()
+
+		entries := listFiles(archive.Archive, db)
+		if format == formatJson {
+			err = writeJson(os.Stdout, entries)
+		} else {
+			err = writeRecords(os.Stdout, format, entries)
+		}
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+	},
+}
+
+type fileEntry struct {
+	Index      uint32
+	Name       string
+	NameHash   string
+	Type       string
+	TypeHash   string
+	InlineSize uint64
+	StreamSize uint64
+	GpuSize    uint64
+}
+
+func listFiles(archive game_data.Archive, db hash_db.HashDB) []fileEntry {
+	files := archive.GetFiles()
+	entries := make([]fileEntry, len(files))
+	for i, file := range files {
+		entries[i] = fileEntry{
+			Index:    uint32(i),
+			Name:     fileName(file.GetName(), db),
+			NameHash: fileName(file.GetName(), nil),
+			Type:     typeName(file.GetType(), db),
+			TypeHash: fmt.Sprintf("%016X", uint64(file.GetType())),
+		}
+		entry := &entries[i]
+		// sizes are taken from tables to not load buffers
+		switch archive := archive.(type) {
+		case *hd1.Archive:
+			for _, variant := range archive.Unpacked.Files[i].VariantHeaders {
+				entry.InlineSize += uint64(variant.Size)
+				entry.StreamSize += uint64(variant.StreamSize)
+			}
+		case *hd2.Archive:
+			file := archive.Files[i]
+			entry.Index = file.Index
+			entry.InlineSize = uint64(file.Size)
+			entry.StreamSize = uint64(file.StreamSize)
+			entry.GpuSize = uint64(file.GpuStreamSize)
+		default:
+			entry.InlineSize = uint64(len(file.GetInlineBuffer()))
+			entry.StreamSize = uint64(len(file.GetStreamBuffer()))
+			entry.GpuSize = uint64(len(file.GetGpuBuffer()))
+		}
+	}
+	return entries
+}
+
+func init() {
+	rootCmd.AddCommand(lsCmd)
+	lsCmd.Flags().String("hash-db", "", "Hash DB file.")
+	addFormatFlag(lsCmd)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd1"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
+	"github.com/Zekfad/hd-tool/game_data/manifest"
+	"github.com/Zekfad/hd-tool/hash_db"
+)
+
+const metadataExtension = ".meta.json"
+
+// fileMetadata describes origin of unpacked file, it's saved next to the file
+// as [name]+metadataExtension sidecar.
+type fileMetadata struct {
+	// Source archive path, patch layer path if file comes from one.
+	Archive string
+	Version string
+	// Index in file table of Archive.
+	Index      int
+	Name       string
+	NameHash   string
+	Type       string
+	TypeHash   string
+	InlineSize uint64
+	StreamSize uint64
+	GpuSize    uint64
+	// Archive specific file table entry, same as in manifest.
+	HD1 *manifest.HD1File `json:",omitempty"`
+	HD2 *manifest.HD2File `json:",omitempty"`
+}
+
+func describeFile(
+	source string,
+	version game_data.ArchiveVersion,
+	index int,
+	entry game_data.File,
+	buffers manifest.Buffers,
+	db hash_db.HashDB,
+) fileMetadata {
+	metadata := fileMetadata{
+		Archive:  source,
+		Version:  fmt.Sprintf("%#X", uint32(version)),
+		Index:    index,
+		Name:     fileName(entry.GetName(), db),
+		NameHash: fileName(entry.GetName(), nil),
+		Type:     typeName(entry.GetType(), db),
+		TypeHash: fmt.Sprintf("%016X", uint64(entry.GetType())),
+	}
+	// sizes are taken from tables to not load buffers
+	switch file := entry.(type) {
+	case hd1.File:
+		for _, variant := range file.VariantHeaders {
+			metadata.InlineSize += uint64(variant.Size)
+			metadata.StreamSize += uint64(variant.StreamSize)
+		}
+		metadata.HD1 = &manifest.HD1File{
+			Type:         file.Type,
+			Name:         file.Name,
+			StreamOffset: file.StreamOffset,
+			Variants:     file.VariantHeaders,
+			Buffers:      buffers,
+		}
+	case hd2.File:
+		metadata.InlineSize = uint64(file.Size)
+		metadata.StreamSize = uint64(file.StreamSize)
+		metadata.GpuSize = uint64(file.GpuStreamSize)
+		metadata.HD2 = &manifest.HD2File{
+			Name:            file.Name,
+			Type:            file.Type,
+			Offset:          file.Offset,
+			StreamOffset:    file.StreamOffset,
+			GpuOffset:       file.GpuOffset,
+			BufferOffset:    file.BufferOffset,
+			GpuBufferOffset: file.GpuBufferOffset,
+			Alignment:       file.Alignment,
+			GpuAlignment:    file.GpuAlignment,
+			Index:           file.Index,
+			Buffers:         buffers,
+		}
+	default:
+		metadata.InlineSize = uint64(len(entry.GetInlineBuffer()))
+		metadata.StreamSize = uint64(len(entry.GetStreamBuffer()))
+		metadata.GpuSize = uint64(len(entry.GetGpuBuffer()))
+	}
+	return metadata
+}
+
+func (metadata fileMetadata) SaveToFile(name string) error {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	return errors.Join(writeJson(file, metadata), file.Close())
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/hash_db"
+)
+
+// fileName returns file name from Hash DB or hex name hash.
+func fileName(name game_data.NameHash, db hash_db.HashDB) string {
+	if filename, dbHasName := db[uint64(name)]; dbHasName {
+		return filename
+	}
+	return fmt.Sprintf("%016X", uint64(name))
+}
+
+// outputName returns slash separated file path relative to unpack or patch
+// directory. Hash DB names are untrusted: if resolved name is not a safe path
+// (see [game_data.SafePath]), hex name hash is used and ok is false.
+func outputName(name game_data.NameHash, db hash_db.HashDB) (filename string, ok bool) {
+	if filename, ok := game_data.SafePath(fileName(name, db)); ok {
+		return filename, true
+	}
+	return fileName(name, nil), false
+}
+
+// typeName returns registered type name, type name from Hash DB or hex type
+// hash. Type names are used as file extensions, so Hash DB names that are not
+// a single path segment are ignored.
+func typeName(_type game_data.TypeHash, db hash_db.HashDB) string {
+	if name, ok := game_data.TypeName(_type); ok {
+		return name
+	}
+	if name, dbHasName := db[uint64(_type)]; dbHasName && isSafeSegment(name) {
+		return name
+	}
+	return _type.String()
+}
+
+func isSafeSegment(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\:\x00")
+}
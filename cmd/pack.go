@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd1"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
+	"github.com/Zekfad/hd-tool/game_data/manifest"
+	"github.com/Zekfad/hd-tool/game_data/writer"
+	"github.com/Zekfad/hd-tool/hash_db"
+	"github.com/spf13/cobra"
+)
+
+const manifestExtension = ".manifest.json"
+
+var packCmd = &cobra.Command{
+	Use:   "pack [source_dir] [new_archive]",
+	Short: "Build game archive from files",
+	Long: `Build game archive either from files unpacked with --manifest flag, or a new
+archive of given --version from a directory of [name].[type] files.
+
+With manifest unchanged files produce the same archive. Compressed chunks of
+HD1 archives are reused from original archive, which is read from path saved
+in manifest or from --original. Manifest only holds chunk checksums, chunks
+with changed data or missing original archive are compressed again.
+
+New archive files are named by slash separated path relative to source
+directory, or hex name hash. Type is resolved type name or hex type hash.
+Stream and GPU buffers are read from [name].[type].stream and
+[name].[type].gpu_resources files (HD1 files have stream buffers only). HD1
+files with several variants are read from [name].variant[N].[type] files.
+Metadata files written by unpack are skipped. Strings resources are encoded from
+[name].strings.json and [name].strings.csv exports, which take precedence over
+raw [name].strings files, JSON exports over CSV ones. CSV has no header fields,
+they are taken from raw file next to it or left zero.
+[name].lua files are wrapped into Lua resource, source files are compiled with
+LuaJIT if compiler is set.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		sourceDirectory := args[0]
+		new := args[1]
+
+		manifestName, err := cmd.Flags().GetString("manifest")
+		if err != nil {
+			fmt.Println("Failed to parse manifest flag")
+			return
+		}
+		version, err := cmd.Flags().GetString("version")
+		if err != nil {
+			fmt.Println("Failed to parse version flag")
+			return
+		}
+		compiler, err := cmd.Flags().GetString("compiler")
+		if err != nil {
+			fmt.Println("Failed to parse compiler flag")
+			return
+		}
+		original, err := cmd.Flags().GetString("original")
+		if err != nil {
+			fmt.Println("Failed to parse original flag")
+			return
+		}
+
+		switch {
+		case manifestName != "" && version != "":
+			fmt.Println("Manifest and version flags can't be used together")
+			return
+		case manifestName != "":
+			err = packManifest(manifestName, sourceDirectory, new, original)
+		case version != "":
+			err = packDirectory(version, sourceDirectory, new, compiler)
+		default:
+			fmt.Println("Either manifest or version is required")
+			return
+		}
+		if err != nil {
+			fmt.Print(err)
+			return
+		}
+	},
+}
+
+func packManifest(manifestName string, sourceDirectory string, new string, original string) error {
+	archiveManifest, err := manifest.FromFile(manifestName)
+	if err != nil {
+		return errors.Join(
+			fmt.Errorf("failed to load manifest"),
+			err,
+		)
+	}
+	if archiveManifest.HD1 != nil && archiveManifest.HD1.Original != nil {
+		hd1Original := archiveManifest.HD1.Original
+		if original != "" {
+			hd1Original.Path = original
+		}
+		if _, err := os.Stat(hd1Original.Path); err != nil {
+			fmt.Printf("Warn: Original archive is not available, all chunks are compressed again: %s\n", err)
+			hd1Original.Path = ""
+		}
+	}
+	archive, err := archiveManifest.Archive(sourceDirectory)
+	if err != nil {
+		return errors.Join(
+			fmt.Errorf("failed to build archive"),
+			err,
+		)
+	}
+	fmt.Printf("Writing archive of version: %#X\n", archive.GetVersion())
+	return writer.WriteArchiveFile(archive, new, true)
+}
+
+func newArchive(version string) (game_data.MutableArchive, error) {
+	switch strings.ToLower(version) {
+	case "hd1":
+		return hd1.NewArchive(), nil
+	case "hd1be":
+		archive := hd1.NewArchive()
+		archive.ByteOrder = binary.BigEndian
+		return archive, nil
+	case "hd2":
+		return hd2.NewArchive(), nil
+	default:
+		return nil, fmt.Errorf("unknown archive version %q, expected hd1, hd1be or hd2", version)
+	}
+}
+
+func packDirectory(version string, sourceDirectory string, new string, compiler string) error {
+	archive, err := newArchive(version)
+	if err != nil {
+		return err
+	}
+
+	// files are added after walk, as variants are collected from several files
+	type fileKey struct {
+		name  game_data.NameHash
+		_type game_data.TypeHash
+	}
+	var keys []fileKey
+	files := map[fileKey]*game_data.FileData{}
+	// formats of strings exports files are added from, exports take precedence
+	// over raw files, JSON exports over CSV ones
+	exported := map[string]game_data.StringsFormat{}
+
+	err = filepath.WalkDir(sourceDirectory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(sourceDirectory, path)
+		if err != nil {
+			return err
+		}
+		relative = filepath.ToSlash(relative)
+		if strings.HasSuffix(relative, manifestExtension) ||
+			strings.HasSuffix(relative, metadataExtension) ||
+			strings.HasSuffix(relative, hd2.StreamExtension) ||
+			strings.HasSuffix(relative, hd2.GpuResourcesExtension) {
+			return nil
+		}
+
+		// strings exports are added as [name].strings files
+		resource := relative
+		format, isExport := game_data.StringsFormatFromPath(relative)
+		if isExport {
+			resource = strings.TrimSuffix(relative, "."+string(format))
+			filename, _, _ := parseVariantName(resource)
+			_, _type, ok := parseFileName(filename)
+			isExport = ok && _type == game_data.Type_strings
+			if !isExport {
+				resource = relative
+			}
+		}
+		if added, ok := exported[resource]; ok &&
+			(!isExport || slices.Index(stringsFormats, format) >= slices.Index(stringsFormats, added)) {
+			fmt.Printf("Warn: File %s is added from %s export, skipping %s\n", resource, added, relative)
+			return nil
+		}
+		readData := readFileData
+		if isExport {
+			exported[resource] = format
+			rawPath := filepath.Join(sourceDirectory, filepath.FromSlash(resource))
+			readData = func(path string) (game_data.FileData, error) {
+				return readStringsFileData(path, format, rawPath, game_data.ByteOrder(archive))
+			}
+		}
+
+		filename, variant, isVariant := parseVariantName(resource)
+		name, _type, ok := parseFileName(filename)
+		if !ok {
+			fmt.Printf("Warn: File %s has no type, skipping\n", relative)
+			return nil
+		}
+
+		key := fileKey{name, _type}
+		if files[key] == nil {
+			files[key] = &game_data.FileData{}
+			keys = append(keys, key)
+		}
+		file := files[key]
+		if isVariant {
+			data, err := readData(path)
+			if err != nil {
+				return errors.Join(
+					fmt.Errorf("failed to read %s", relative),
+					err,
+				)
+			}
+			if variant >= len(file.Variants) {
+				file.Variants = append(file.Variants, make([][]byte, variant+1-len(file.Variants))...)
+			}
+			file.Variants[variant] = data.Inline
+			if data.Stream != nil {
+				if variant >= len(file.VariantStreams) {
+					file.VariantStreams = append(file.VariantStreams, make([][]byte, variant+1-len(file.VariantStreams))...)
+				}
+				file.VariantStreams[variant] = data.Stream
+			}
+			fmt.Printf("Adding %s\n", relative)
+			return nil
+		}
+
+		var data game_data.FileData
+		if _type == game_data.Type_lua {
+			data.Inline, err = packLuaFile(path, game_data.ByteOrder(archive), compiler)
+		} else {
+			data, err = readData(path)
+		}
+		if err != nil {
+			return errors.Join(
+				fmt.Errorf("failed to read %s", relative),
+				err,
+			)
+		}
+		data.Variants, data.VariantStreams = file.Variants, file.VariantStreams
+		*file = data
+
+		fmt.Printf("Adding %s\n", relative)
+		return nil
+	})
+	for _, key := range keys {
+		if err != nil {
+			break
+		}
+		err = archive.AddFile(key.name, key._type, *files[key])
+	}
+	if err != nil {
+		return errors.Join(
+			fmt.Errorf("failed to build archive"),
+			err,
+		)
+	}
+	fmt.Printf("Writing archive of version: %#X\n", archive.GetVersion())
+	return writer.WriteArchiveFile(archive, new, true)
+}
+
+// parseFileName splits [name].[type] into name and type hashes.
+// Name of 16 hex digits is a name hash.
+func parseFileName(filename string) (game_data.NameHash, game_data.TypeHash, bool) {
+	dot := strings.LastIndexByte(filename, '.')
+	if dot <= 0 || strings.Contains(filename[dot:], "/") {
+		return 0, 0, false
+	}
+	_type, err := game_data.ParseTypeHash(filename[dot+1:])
+	if err != nil {
+		return 0, 0, false
+	}
+	name := filename[:dot]
+	if len(name) == 16 {
+		if hash, err := strconv.ParseUint(name, 16, 64); err == nil {
+			return hash, _type, true
+		}
+	}
+	return hash_db.Hash(name), _type, true
+}
+
+// parseVariantName splits [name].variant[N].[type] into [name].[type] and
+// variant index.
+func parseVariantName(filename string) (string, int, bool) {
+	dot := strings.LastIndexByte(filename, '.')
+	if dot <= 0 {
+		return filename, 0, false
+	}
+	name, extension := filename[:dot], filename[dot:]
+	variantDot := strings.LastIndexByte(name, '.')
+	if variantDot < 0 {
+		return filename, 0, false
+	}
+	digits, ok := strings.CutPrefix(name[variantDot+1:], "variant")
+	if !ok || digits == "" || strings.ContainsAny(digits, "+-") {
+		return filename, 0, false
+	}
+	variant, err := strconv.Atoi(digits)
+	if err != nil {
+		return filename, 0, false
+	}
+	return name[:variantDot] + extension, variant, true
+}
+
+// readFileData reads file along with its stream and gpu resources files.
+func readFileData(path string) (game_data.FileData, error) {
+	var data game_data.FileData
+	var err error
+	if data.Inline, err = os.ReadFile(path); err != nil {
+		return data, err
+	}
+	return data, readCompanionFiles(path, &data)
+}
+
+// readStringsFileData encodes strings resource from export, stream and gpu
+// resources files are read along with raw resource file, which provides
+// header fields missing from export.
+func readStringsFileData(path string, format game_data.StringsFormat, rawPath string, order binary.ByteOrder) (game_data.FileData, error) {
+	var data game_data.FileData
+	original, err := os.ReadFile(rawPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return data, err
+	}
+	if data.Inline, err = importStrings(path, format, original, order); err != nil {
+		return data, err
+	}
+	return data, readCompanionFiles(rawPath, &data)
+}
+
+// readCompanionFiles reads stream and gpu resources files of file at path.
+func readCompanionFiles(path string, data *game_data.FileData) error {
+	for _, companion := range []struct {
+		extension string
+		buffer    *[]byte
+	}{
+		{hd2.StreamExtension, &data.Stream},
+		{hd2.GpuResourcesExtension, &data.Gpu},
+	} {
+		buffer, err := os.ReadFile(path + companion.extension)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		*companion.buffer = buffer
+	}
+	return nil
+}
+
+// luaJITMagic starts LuaJIT bytecode.
+var luaJITMagic = []byte("\x1bLJ")
+
+// packLuaFile wraps script into Lua resource. Source is compiled if compiler is
+// set, otherwise it's stored as is.
+func packLuaFile(path string, order binary.ByteOrder, compiler string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := game_data.LuaFormatSource
+	if bytes.HasPrefix(data, luaJITMagic) {
+		format = game_data.LuaFormatLuaJIT2
+	} else if compiler != "" {
+		if data, err = compileLuaJIT(compiler, path); err != nil {
+			return nil, err
+		}
+		format = game_data.LuaFormatLuaJIT2
+	}
+	return game_data.LuaResource{
+		Format:    format,
+		Data:      data,
+		ByteOrder: order,
+	}.ToBytes()
+}
+
+func init() {
+	rootCmd.AddCommand(packCmd)
+	packCmd.Flags().String("manifest", "", "Manifest saved by unpack --manifest")
+	packCmd.Flags().String("version", "", "Version of new archive: hd1, hd1be (big-endian console HD1) or hd2")
+	packCmd.Flags().String("compiler", "", "Path to LuaJIT 2.0.3, compiles Lua sources of new archive")
+	packCmd.Flags().String("original", "", "Original HD1 archive to reuse compressed chunks from, overrides path saved in manifest")
+}
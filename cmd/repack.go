@@ -1,25 +1,43 @@
 package cmd
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 
 	"github.com/Zekfad/hd-tool/game_data"
-	"github.com/Zekfad/hd-tool/game_data/hd1"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
 	"github.com/Zekfad/hd-tool/game_data/reader"
-	"github.com/Zekfad/hd-tool/game_data/writer/writer_hd1"
+	"github.com/Zekfad/hd-tool/game_data/writer"
 	"github.com/Zekfad/hd-tool/hash_db"
 	"github.com/spf13/cobra"
 )
 
 var repackCmd = &cobra.Command{
 	Use:   "repack [original_archive] [new_archive] [patch_directory]",
-	Short: "Patch game archive (only HD1)",
-	Long:  `Patch HD1 archive to replace Lua scripts`,
-	Args:  cobra.ExactArgs(3),
+	Short: "Patch game archive",
+	Long: `Patch HD1 or HD2 archive to replace resources.
+
+Patch directory holds raw replacement files named [name].[type], where name is
+either resolved file name or hex name hash and type is resolved type name or hex
+type hash. Stream and GPU buffers are replaced by [name].[type].stream and
+[name].[type].gpu_resources files. Variants of HD1 files with several ones are
+replaced individually by [name].variant[N].[type] files, their stream buffers
+by [name].variant[N].[type].stream files.
+
+Lua scripts are patched from source: [name].lua is compiled with LuaJIT and
+wrapped into Lua resource.
+
+Strings resources are patched from exports written by unpack:
+[name].strings.json or [name].strings.csv (or [name].variant[N].strings.json and
+.csv for variants) is encoded into resource, CSV keeps header fields of the
+original resource. Exports take precedence over raw [name].strings files, JSON
+exports over CSV ones.`,
+	Args: cobra.ExactArgs(3),
 	Run: func(cmd *cobra.Command, args []string) {
 		original := args[0]
 		new := args[1]
@@ -36,10 +54,6 @@ var repackCmd = &cobra.Command{
 			fmt.Println("Failed to parse compiler flag")
 			return
 		}
-		if compiler == "" {
-			fmt.Println("Compiler is required")
-			return
-		}
 
 		var db = hash_db.HashDB{}
 		if dbName != "" {
@@ -58,6 +72,9 @@ var repackCmd = &cobra.Command{
 	},
 }
 
+// filePatch holds replacement buffers of a file, nil buffer is left unchanged.
+type filePatch = game_data.FileData
+
 func repackFile(
 	original string,
 	new string,
@@ -78,52 +95,242 @@ func repackFile(
 			err,
 		)
 	}
-	archive, ok := src.(hd1.Archive)
-	if !ok {
-		return fmt.Errorf("unsupported archive")
+
+	archive, err := game_data.Mutate(src)
+	if err != nil {
+		return err
 	}
 
-	for _, entry := range archive.Unpacked.Files {
-		if entry.GetType() != game_data.Type_lua {
+	withCompanions := hasCompanionFiles(src, original)
+	for _, entry := range src.GetFiles() {
+		patch, ok := findPatch(entry, game_data.ByteOrder(src), compiler, patchDirectory, db)
+		if !ok {
 			continue
 		}
+		if err := archive.ReplaceFile(entry.GetName(), entry.GetType(), patch); err != nil {
+			fmt.Printf("Warn: Failed to patch %s: %s, skipping\n", fileName(entry.GetName(), db), err)
+			continue
+		}
+		if patch.Stream != nil || patch.Gpu != nil || patch.VariantStreams != nil {
+			withCompanions = true
+		}
+	}
 
-		filename, dbHasName := db[uint64(entry.GetName())]
-		if !dbHasName {
-			filename = fmt.Sprintf("%016X", entry.GetName())
+	return writer.WriteArchiveFile(archive, new, withCompanions)
+}
+
+// hasCompanionFiles reports whether companion files of original archive exist,
+// so that they must be rewritten along with the main file.
+func hasCompanionFiles(archive game_data.Archive, name string) bool {
+	companionArchive, ok := archive.(game_data.CompanionArchive)
+	if !ok {
+		return false
+	}
+	for _, extension := range companionArchive.GetCompanionExtensions() {
+		if _, err := os.Stat(name + extension); err == nil {
+			return true
 		}
-		filePath := filepath.Join(patchDirectory, filename+".lua")
+	}
+	return false
+}
 
-		// fmt.Printf("Searching for patch %s\n", filePath)
-		if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
-			continue
+// findPatch looks up replacement buffers for entry in patch directory.
+// Resolved name is preferred over hex name hash.
+func findPatch(
+	entry game_data.File,
+	order binary.ByteOrder,
+	compiler string,
+	patchDirectory string,
+	db hash_db.HashDB,
+) (filePatch, bool) {
+	hexName := fileName(entry.GetName(), nil)
+	names := []string{hexName}
+	// unsafe names are looked up by hex name hash, same as they are unpacked
+	if filename, ok := outputName(entry.GetName(), db); ok && filename != hexName {
+		names = []string{filename, hexName}
+	}
+
+	var variants int
+	if entry, ok := entry.(game_data.VariantFile); ok && len(entry.GetVariants()) > 1 {
+		variants = len(entry.GetVariants())
+	}
+
+	// scripts with variants are patched raw, same as they are unpacked
+	if entry.GetType() == game_data.Type_lua && variants == 0 {
+		for _, name := range names {
+			if patch, ok := patchLuaEntry(entry, order, compiler, filepath.Join(patchDirectory, name+".lua")); ok {
+				return patch, true
+			}
 		}
+		return filePatch{}, false
+	}
 
-		fmt.Printf("Compiling patch script %s ... ", filePath)
+	extension := typeName(entry.GetType(), db)
+	for _, name := range names {
+		filePath := filepath.Join(patchDirectory, name+"."+extension)
+		if entry.GetType() == game_data.Type_strings {
+			if patch, ok := findStringsPatch(entry, order, filePath, extension, variants); ok {
+				return patch, true
+			}
+		}
+		if variants > 0 {
+			if patch, ok := findVariantsPatch(filePath, extension, variants); ok {
+				return patch, true
+			}
+		}
 
-		data, err := compileLuaJIT(compiler, filePath)
+		var patch filePatch
+		var found bool
+		for _, part := range []struct {
+			path   string
+			buffer *[]byte
+		}{
+			{filePath, &patch.Inline},
+			{filePath + hd2.StreamExtension, &patch.Stream},
+			{filePath + hd2.GpuResourcesExtension, &patch.Gpu},
+		} {
+			data, err := os.ReadFile(part.path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				fmt.Printf("Warn: Failed to read patch %s: %s\n", part.path, err)
+				continue
+			}
+			fmt.Printf("Found patch %s\n", part.path)
+			*part.buffer = data
+			found = true
+		}
+		if found {
+			return patch, true
+		}
+	}
+	return filePatch{}, false
+}
+
+// findVariantsPatch looks up replacement buffers of individual variants.
+// VariantStreams are nil unless a variant stream file is found.
+func findVariantsPatch(filePath string, extension string, variants int) (filePatch, bool) {
+	patch := filePatch{
+		Variants: make([][]byte, variants),
+	}
+	streams := make([][]byte, variants)
+	var found bool
+	for i := range variants {
+		variantPath := variantFileName(filePath, extension, i)
+		for _, part := range []struct {
+			path   string
+			buffer *[]byte
+		}{
+			{variantPath, &patch.Variants[i]},
+			{variantPath + hd2.StreamExtension, &streams[i]},
+		} {
+			data, err := os.ReadFile(part.path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				fmt.Printf("Warn: Failed to read patch %s: %s\n", part.path, err)
+				continue
+			}
+			fmt.Printf("Found patch %s\n", part.path)
+			*part.buffer = data
+			found = true
+		}
+	}
+	if slices.ContainsFunc(streams, func(stream []byte) bool { return stream != nil }) {
+		patch.VariantStreams = streams
+	}
+	return patch, found
+}
+
+// findStringsPatch looks up strings exports of resource or of its individual
+// variants, exports take precedence over raw replacement files.
+func findStringsPatch(
+	entry game_data.File,
+	order binary.ByteOrder,
+	filePath string,
+	extension string,
+	variants int,
+) (filePatch, bool) {
+	inline, err := game_data.ReadInlineBuffer(entry)
+	if err != nil {
+		fmt.Printf("Warn: Failed to read original strings %s: %s\n", filePath, err)
+		return filePatch{}, false
+	}
+	originals := [][]byte{inline}
+	if variants > 0 {
+		originals = entry.(game_data.VariantFile).GetVariants()
+	}
+	patch := filePatch{}
+	if variants > 0 {
+		patch.Variants = make([][]byte, variants)
+	}
+	var found bool
+	for i, original := range originals {
+		resourcePath := filePath
+		if variants > 0 {
+			resourcePath = variantFileName(filePath, extension, i)
+		}
+		exportPath, format, ok := findStringsExport(resourcePath)
+		if !ok {
+			continue
+		}
+		fmt.Printf("Importing patch strings %s ... ", exportPath)
+		data, err := importStrings(exportPath, format, original, order)
 		if err != nil {
 			fmt.Printf("error: %s\n", err)
 			continue
 		}
-		fmt.Printf("done ... ")
-		lua, _ := game_data.LuaResourceFromBytes(entry.GetInlineBuffer())
-		lua.Data = data
-		patchedResource, _ := lua.ToBytes()
-		entry.VariantBuffers[0] = patchedResource
+		fmt.Printf("done\n")
+		if variants > 0 {
+			patch.Variants[i] = data
+		} else {
+			patch.Inline = data
+		}
+		found = true
+	}
+	return patch, found
+}
 
-		fmt.Printf("patched %s!\n", filename)
+func patchLuaEntry(
+	entry game_data.File,
+	order binary.ByteOrder,
+	compiler string,
+	filePath string,
+) (filePatch, bool) {
+	// fmt.Printf("Searching for patch %s\n", filePath)
+	if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
+		return filePatch{}, false
 	}
+	if compiler == "" {
+		fmt.Printf("Warn: Compiler is required to patch script %s, skipping\n", filePath)
+		return filePatch{}, false
+	}
+
+	fmt.Printf("Compiling patch script %s ... ", filePath)
 
-	file, err := os.OpenFile(new, os.O_CREATE|os.O_TRUNC, 0666)
+	data, err := compileLuaJIT(compiler, filePath)
 	if err != nil {
-		return errors.Join(
-			fmt.Errorf("failed to open file"),
-			err,
-		)
+		fmt.Printf("error: %s\n", err)
+		return filePatch{}, false
+	}
+	fmt.Printf("done ... ")
+	original, err := game_data.ReadInlineBuffer(entry)
+	if err != nil {
+		fmt.Printf("failed to read original resource: %s\n", err)
+		return filePatch{}, false
+	}
+	lua, err := game_data.LuaResourceFromBytesWithOrder(original, order)
+	if err != nil {
+		fmt.Printf("invalid original resource: %s\n", err)
+		return filePatch{}, false
 	}
-	defer file.Close()
-	return writer_hd1.WriteArchive(archive, file)
+	lua.Data = data
+	patchedResource, _ := lua.ToBytes()
+
+	fmt.Printf("patched %s!\n", filePath)
+	return filePatch{Inline: patchedResource}, true
 }
 
 func compileLuaJIT(luajit string, script string) ([]byte, error) {
@@ -148,5 +355,5 @@ func compileLuaJIT(luajit string, script string) ([]byte, error) {
 func init() {
 	rootCmd.AddCommand(repackCmd)
 	repackCmd.Flags().String("hash-db", "", "Hash DB file.")
-	repackCmd.Flags().String("compiler", "", "Required: Path to LuaJIT 2.0.3")
+	repackCmd.Flags().String("compiler", "", "Path to LuaJIT 2.0.3, required to patch Lua scripts")
 }
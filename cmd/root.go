@@ -3,12 +3,23 @@ package cmd
 import (
 	"os"
 
+	"github.com/Zekfad/hd-tool/game_data"
 	"github.com/spf13/cobra"
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "hd-tool",
 	Short: "HD tool",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		typeNames, err := cmd.Flags().GetString("type-names")
+		if err != nil {
+			return err
+		}
+		if typeNames == "" {
+			return nil
+		}
+		return game_data.RegisterTypeNamesFromFile(typeNames)
+	},
 }
 
 func Execute() {
@@ -19,4 +30,5 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.PersistentFlags().String("type-names", "", "File with additional type names, one per line.")
 }
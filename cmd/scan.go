@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Zekfad/hd-tool/game_data/reader"
+	"github.com/Zekfad/hd-tool/glob"
+	"github.com/spf13/cobra"
+)
+
+func addScanFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("jobs", 0, "number of packages to read in parallel (default is number of CPUs)")
+	cmd.Flags().BoolP("recursive", "r", false, "scan subdirectories")
+	cmd.Flags().StringSlice("include", nil, "only scan packages with relative path matching glob (\"**\" matches any directories)")
+	cmd.Flags().StringSlice("exclude", nil, "skip packages with relative path matching glob")
+	addPatchesFlag(cmd)
+}
+
+func addPatchesFlag(cmd *cobra.Command) {
+	cmd.Flags().String("patches", "overlay", "patch layers (<package>.patch_N): overlay (apply on top of package), separate or ignore")
+}
+
+func patchModeFromFlags(cmd *cobra.Command) (reader.PatchMode, error) {
+	patches, err := cmd.Flags().GetString("patches")
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse patches flag")
+	}
+	switch patches {
+	case "overlay":
+		return reader.PatchesOverlay, nil
+	case "separate":
+		return reader.PatchesSeparate, nil
+	case "ignore":
+		return reader.PatchesIgnore, nil
+	default:
+		return 0, fmt.Errorf("unknown patches mode %q, expected overlay, separate or ignore", patches)
+	}
+}
+
+func scanOptionsFromFlags(cmd *cobra.Command) (reader.ScanOptions, error) {
+	jobs, err := cmd.Flags().GetInt("jobs")
+	if err != nil {
+		return reader.ScanOptions{}, fmt.Errorf("failed to parse jobs flag")
+	}
+	recursive, err := cmd.Flags().GetBool("recursive")
+	if err != nil {
+		return reader.ScanOptions{}, fmt.Errorf("failed to parse recursive flag")
+	}
+	include, err := cmd.Flags().GetStringSlice("include")
+	if err != nil {
+		return reader.ScanOptions{}, fmt.Errorf("failed to parse include flag")
+	}
+	exclude, err := cmd.Flags().GetStringSlice("exclude")
+	if err != nil {
+		return reader.ScanOptions{}, fmt.Errorf("failed to parse exclude flag")
+	}
+	for _, pattern := range append(include, exclude...) {
+		if err := glob.Validate(pattern); err != nil {
+			return reader.ScanOptions{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	patches, err := patchModeFromFlags(cmd)
+	if err != nil {
+		return reader.ScanOptions{}, err
+	}
+	return reader.ScanOptions{
+		Recursive: recursive,
+		Include:   include,
+		Exclude:   exclude,
+		Jobs:      jobs,
+		Patches:   patches,
+	}, nil
+}
+
+// scanSummary counts scanned candidates: files of unknown version are skipped
+// silently, while truncated, corrupt and unreadable ones are listed as broken.
+type scanSummary struct {
+	archives int
+	skipped  int
+	broken   []reader.ScanResult
+}
+
+// add records result and reports whether it holds an archive.
+func (summary *scanSummary) add(result reader.ScanResult) bool {
+	switch {
+	case result.Err == nil:
+		summary.archives++
+		return true
+	case errors.Is(result.Err, reader.ErrUnknownVersion):
+		summary.skipped++
+	default:
+		summary.broken = append(summary.broken, result)
+	}
+	return false
+}
+
+func (summary *scanSummary) Print(w io.Writer) {
+	fmt.Fprintf(w, "Scanned %d packages, skipped %d non-package files, %d broken packages\n",
+		summary.archives, summary.skipped, len(summary.broken))
+	for _, result := range summary.broken {
+		fmt.Fprintf(w, "Broken %s: %s\n", result.Path, oneLine(result.Err))
+	}
+}
+
+// oneLine flattens joined errors into a single line.
+func oneLine(err error) string {
+	return strings.ReplaceAll(err.Error(), "\n", ": ")
+}
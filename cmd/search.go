@@ -2,7 +2,7 @@ package cmd
 
 import (
 	"fmt"
-	"strconv"
+	"os"
 
 	"github.com/Zekfad/hd-tool/game_data"
 	"github.com/Zekfad/hd-tool/game_data/reader"
@@ -10,13 +10,15 @@ import (
 )
 
 var searchCmd = &cobra.Command{
-	Use:   "search [folder] [target]",
+	Use:   "search [folder] [type]",
 	Short: "Search for package with a given type",
-	Long:  `Scan folder for packages and files of given type.`,
-	Args:  cobra.ExactArgs(2),
+	Long: `Scan folder for packages and files of given type.
+
+Type is either type name (e.g. texture) or hex type hash.`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		dirname := args[0]
-		target, err := strconv.ParseUint(args[1], 16, 64)
+		targetType, err := game_data.ParseTypeHash(args[1])
 		if err != nil {
 			fmt.Printf("failed to parse target: %s", err)
 			return
@@ -28,24 +30,34 @@ var searchCmd = &cobra.Command{
 			return
 		}
 
-		targetType := game_data.TypeHash(target)
+		options, err := scanOptionsFromFlags(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
 
+		var summary scanSummary
 	archives_loop:
-		for path, archive := range reader.ArchivesFromDirectory(dirname) {
-			fmt.Println("Checking:", path)
-			for i, file := range archive.GetFiles() {
+		for result := range reader.ArchivesFromDirectory(dirname, options) {
+			if !summary.add(result) {
+				continue
+			}
+			fmt.Println("Checking:", result.Path)
+			for i, file := range result.Archive.GetFiles() {
 				if targetType == file.GetType() {
-					fmt.Printf("Found match in archive: %s file %d\n", path, i)
+					fmt.Printf("Found %s in archive: %s file %d\n", targetType, result.Path, i)
 					if !all {
 						break archives_loop
 					}
 				}
 			}
 		}
+		summary.Print(os.Stdout)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(searchCmd)
 	searchCmd.Flags().Bool("all", false, "find all matches")
+	addScanFlags(searchCmd)
 }
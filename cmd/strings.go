@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Zekfad/hd-tool/game_data"
+)
+
+// stringsFormats are formats of strings exports in lookup order.
+var stringsFormats = []game_data.StringsFormat{
+	game_data.StringsFormatJSON,
+	game_data.StringsFormatCSV,
+}
+
+func exportStrings(name string, resource *game_data.StringsResource, format game_data.StringsFormat) error {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	return errors.Join(resource.Export(file, format), file.Close())
+}
+
+// importStrings encodes strings resource from export file, header fields
+// missing from export are taken from original resource if it's set.
+func importStrings(name string, format game_data.StringsFormat, original []byte, order binary.ByteOrder) ([]byte, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := game_data.ImportStrings(file, format, original, order)
+	if err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("failed to import strings %s", name),
+			err,
+		)
+	}
+	return data, nil
+}
+
+// findStringsExport returns path and format of export of strings resource file,
+// ok is false if there's none.
+func findStringsExport(filePath string) (string, game_data.StringsFormat, bool) {
+	for _, format := range stringsFormats {
+		exportPath := filePath + "." + string(format)
+		if _, err := os.Stat(exportPath); err == nil {
+			return exportPath, format, true
+		}
+	}
+	return "", "", false
+}
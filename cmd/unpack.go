@@ -1,14 +1,23 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd1"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
+	"github.com/Zekfad/hd-tool/game_data/manifest"
 	"github.com/Zekfad/hd-tool/game_data/reader"
 	"github.com/Zekfad/hd-tool/hash_db"
+	"github.com/Zekfad/hd-tool/pool"
 	"github.com/spf13/cobra"
 )
 
@@ -20,28 +29,13 @@ var unpackCmd = &cobra.Command{
 		archiveName := args[0]
 		targetDirectory := args[1]
 
-		dbName, err := cmd.Flags().GetString("hash-db")
+		options, err := unpackOptionsFromFlags(cmd)
 		if err != nil {
-			fmt.Println("Failed to parse hash db flag")
+			fmt.Println(err)
 			return
 		}
 
-		unknown, err := cmd.Flags().GetBool("unknown")
-		if err != nil {
-			fmt.Println("Failed to parse unknown flag")
-			return
-		}
-
-		var db = hash_db.HashDB{}
-		if dbName != "" {
-			db, err = hash_db.FromFile(dbName, false)
-			if err != nil {
-				fmt.Printf("Failed load hash db %s\n", err)
-				return
-			}
-		}
-
-		err = unpackFile(archiveName, targetDirectory, unknown, db)
+		err = unpackFile(archiveName, targetDirectory, options, &unpackOutput{log: os.Stdout})
 		if err != nil {
 			fmt.Print(err)
 			return
@@ -57,123 +51,706 @@ var unpackAllCmd = &cobra.Command{
 		archivesDirectory := args[0]
 		targetDirectory := args[1]
 
-		dbName, err := cmd.Flags().GetString("hash-db")
+		options, err := unpackOptionsFromFlags(cmd)
 		if err != nil {
-			fmt.Println("Failed to parse hash db flag")
+			fmt.Println(err)
 			return
 		}
 
-		unknown, err := cmd.Flags().GetBool("unknown")
+		scanOptions, err := scanOptionsFromFlags(cmd)
 		if err != nil {
-			fmt.Println("Failed to parse unknown flag")
+			fmt.Println(err)
 			return
 		}
+		// manifest describes a single file, so patch layers are unpacked on
+		// their own after base archives
+		if options.Manifest && scanOptions.Patches == reader.PatchesOverlay {
+			scanOptions.Patches = reader.PatchesSeparate
+		}
 
-		var db = hash_db.HashDB{}
-		if dbName != "" {
-			db, err = hash_db.FromFile(dbName, false)
+		var summary scanSummary
+		paths, err := reader.ArchivePaths(archivesDirectory, scanOptions)
+		if err != nil {
+			summary.add(reader.ScanResult{Path: archivesDirectory, Err: err})
+		}
+
+		indices := make([]int, len(paths))
+		for i := range indices {
+			indices[i] = i
+		}
+
+		type unpacked struct {
+			log *bytes.Buffer
+			err error
+		}
+		claims := newOutputClaims()
+		unpack := func(i int) unpacked {
+			fullPath := paths[i]
+			log := new(bytes.Buffer)
+			output := &unpackOutput{
+				log:    log,
+				claims: claims,
+				order:  i,
+			}
+			output.Printf("Trying to unpack candidate %s\n", fullPath)
+			err := unpackFile(fullPath, targetDirectory, options, output)
 			if err != nil {
-				fmt.Printf("Failed load hash db %s\n", err)
-				return
+				output.Printf("Failed: %s\n", err)
+				return unpacked{log, err}
 			}
+			output.Printf("Unpacked %s!\n", fullPath)
+			return unpacked{log, nil}
 		}
 
-		entries, err := os.ReadDir(archivesDirectory)
-		if err != nil {
-			fmt.Println(err)
-			return
+		for i, result := range pool.Map(indices, scanOptions.Jobs, unpack, nil) {
+			result.log.WriteTo(os.Stdout)
+			summary.add(reader.ScanResult{Path: paths[i], Err: result.err})
 		}
+		summary.Print(os.Stdout)
+	},
+}
 
-		for _, file := range entries {
-			if file.IsDir() {
-				continue
-			}
-			if filepath.Ext(file.Name()) == "" {
-				fullPath := filepath.Join(archivesDirectory, file.Name())
+type unpackOptions struct {
+	// save raw buffers of unknown file formats
+	Unknown bool
+	// save all files along with manifest to rebuild archive
+	Manifest bool
+	// patch layers are applied only in overlay mode, unpacking with manifest
+	// ignores them
+	Patches reader.PatchMode
+	// files to unpack, selected files of unknown formats are saved raw
+	Filter fileFilter
+	// save metadata sidecar of every unpacked file
+	Metadata bool
+	// format of exported strings resources
+	StringsFormat game_data.StringsFormat
+	Db            hash_db.HashDB
+}
 
-				fmt.Printf("Trying to unpack candidate %s\n", fullPath)
-				err = unpackFile(fullPath, targetDirectory, unknown, db)
+func unpackOptionsFromFlags(cmd *cobra.Command) (unpackOptions, error) {
+	dbName, err := cmd.Flags().GetString("hash-db")
+	if err != nil {
+		return unpackOptions{}, fmt.Errorf("failed to parse hash db flag")
+	}
 
-				if err != nil {
-					fmt.Printf("Failed: %s\n", err)
-					continue
-				}
-				fmt.Printf("Unpacked %s!\n", fullPath)
-			}
+	unknown, err := cmd.Flags().GetBool("unknown")
+	if err != nil {
+		return unpackOptions{}, fmt.Errorf("failed to parse unknown flag")
+	}
+
+	manifest, err := cmd.Flags().GetBool("manifest")
+	if err != nil {
+		return unpackOptions{}, fmt.Errorf("failed to parse manifest flag")
+	}
+
+	metadata, err := cmd.Flags().GetBool("metadata")
+	if err != nil {
+		return unpackOptions{}, fmt.Errorf("failed to parse metadata flag")
+	}
+
+	stringsFormat, err := cmd.Flags().GetString("strings-format")
+	if err != nil {
+		return unpackOptions{}, fmt.Errorf("failed to parse strings format flag")
+	}
+	format, err := game_data.ParseStringsFormat(stringsFormat)
+	if err != nil {
+		return unpackOptions{}, err
+	}
+
+	patches, err := patchModeFromFlags(cmd)
+	if err != nil {
+		return unpackOptions{}, err
+	}
+
+	filter, err := filterFromFlags(cmd)
+	if err != nil {
+		return unpackOptions{}, err
+	}
+	if manifest && filter.IsSet() {
+		return unpackOptions{}, fmt.Errorf("filters can't be used with manifest, as it describes all files")
+	}
+
+	var db = hash_db.HashDB{}
+	if dbName != "" {
+		db, err = hash_db.FromFile(dbName, false)
+		if err != nil {
+			return unpackOptions{}, fmt.Errorf("failed load hash db %w", err)
 		}
-	},
+	}
+
+	return unpackOptions{
+		Unknown:       unknown,
+		Manifest:      manifest,
+		Patches:       patches,
+		Filter:        filter,
+		Metadata:      metadata,
+		StringsFormat: format,
+		Db:            db,
+	}, nil
 }
 
-func unpackFile(name string, targetDirectory string, unknown bool, db hash_db.HashDB) error {
+func unpackFile(name string, targetDirectory string, options unpackOptions, output *unpackOutput) error {
 	if err := ensureDir(targetDirectory); err != nil {
 		return errors.Join(
 			fmt.Errorf("failed to create target directory"),
 			err,
 		)
 	}
-	archive, err := reader.ArchiveFromFile(name)
-	if err != nil {
-		return errors.Join(
-			fmt.Errorf("failed to read archive"),
-			err,
-		)
+
+	db := options.Db
+	var version game_data.ArchiveVersion
+	var order binary.ByteOrder
+	var buffers []manifest.Buffers
+	usedNames := map[string]bool{}
+	opened := func(archiveVersion game_data.ArchiveVersion, byteOrder binary.ByteOrder, patches int) {
+		version, order = archiveVersion, byteOrder
+		output.Printf("Loaded archive of version: %#X\n", version)
+		if reader.Experimental(version) {
+			output.Printf("Warn: Support of archive version %#X is experimental, it can't be packed back\n", version)
+		}
+		if patches > 0 {
+			output.Printf("Applied %d patch layers\n", patches)
+		}
 	}
+	unpack := func(entry game_data.File, source string, index int, readers entryReaders) error {
+		var entryBuffers manifest.Buffers
+		// unpacked file name, variants files share it
+		var written string
+		defer func() {
+			buffers = append(buffers, entryBuffers)
+			if !options.Metadata || written == "" {
+				return
+			}
+			metadata := describeFile(source, version, index, entry, entryBuffers, db)
+			metadataName := filepath.Join(targetDirectory, written+metadataExtension)
+			if err := output.claim(metadataName, func() error {
+				return metadata.SaveToFile(metadataName)
+			}); err != nil {
+				output.Printf("Warn: Failed to write metadata file: %s\n", err)
+			}
+		}()
+		if !options.Filter.Match(entry, db) {
+			return nil
+		}
 
-	fmt.Printf("Loaded archive of version: %#X\n", archive.GetVersion())
-	for _, entry := range archive.GetFiles() {
-		filename, dbHasName := db[uint64(entry.GetName())]
-		if !dbHasName {
-			filename = fmt.Sprintf("%016X", entry.GetName())
+		filename, ok := outputName(entry.GetName(), db)
+		if !ok {
+			output.Printf("Warn: Unsafe file name %q, using name hash\n", fileName(entry.GetName(), db))
 		}
-		filePath := filepath.Join(targetDirectory, filename)
+		if readers.StreamErr != nil {
+			output.Printf("Warn: Stream buffers of %s are not unpacked: %s\n", filename, readers.StreamErr)
+		}
+		raw := options.Unknown || options.Manifest || options.Filter.IsSet()
 		switch entry.GetType() {
 		case game_data.Type_lua:
-			fmt.Printf("Found script %s ... ", filename)
+			// scripts with variants or stream buffers are saved raw
+			if readers.Variants != nil || readers.Stream != nil {
+				raw = true
+				break
+			}
+			filePath := filepath.Join(targetDirectory, filename)
+			output.Printf("Found script %s ... ", filename)
+
+			// scripts are decoded in memory, raw buffer may still be needed
+			inline, err := io.ReadAll(readers.Inline)
+			if err != nil {
+				return err
+			}
+			readers.Inline = bytes.NewReader(inline)
 
-			lua, err := game_data.LuaResourceFromBytes(entry.GetInlineBuffer())
+			lua, err := game_data.LuaResourceFromBytesWithOrder(inline, order)
 			if err != nil {
-				fmt.Printf("invalid: %s\n", err)
+				output.Printf("invalid: %s\n", err)
 				break
 			}
-			fmt.Printf("valid (format: %#X)\n", lua.Format)
+			output.Printf("valid (format: %#X)\n", lua.Format)
+			// raw buffer is kept if resource can't be restored from script
+			if options.Manifest {
+				resource, err := lua.ToBytes()
+				if err != nil || !bytes.Equal(resource, inline) {
+					break
+				}
+			}
+			raw = false
+			usedNames[filename] = true
 
 			if err := ensureDir(filepath.Dir(filePath)); err != nil {
-				fmt.Printf("Warn: Failed to create target directory error: %s\n", err)
+				output.Printf("Warn: Failed to create target directory error: %s\n", err)
 				break
 			}
 
-			file, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC, 0666)
-			if err != nil {
-				fmt.Printf("Warn: Failed to open target file: %s\n", err)
+			if err := output.writeBuffer(filePath, lua.Data); err != nil {
+				output.Printf("Warn: Failed to write target file: %s\n", err)
 				break
 			}
-			defer file.Close()
-
-			file.Write(lua.Data)
-			break
-		default:
-			if !unknown {
+			entryBuffers = manifest.Buffers{
+				Inline:    filepath.ToSlash(filename),
+				LuaFormat: &lua.Format,
+			}
+			written = filename
+		case game_data.Type_strings:
+			// strings with stream buffers are saved raw
+			if readers.Stream != nil || readers.VariantStreams != nil {
+				raw = true
 				break
 			}
-			fmt.Printf("Found unknown file %s\n", filename)
+			// resources are decoded in memory, raw buffers may still be needed
+			resources, err := readInlineBuffers(&readers)
+			if err != nil {
+				return err
+			}
+			extension := typeName(entry.GetType(), db)
+			resourceName := filename + "." + extension
+			var exports []string
+			for j, data := range resources {
+				exportName := resourceName
+				if readers.Variants != nil {
+					exportName = variantFileName(resourceName, extension, j)
+				}
+				exportName += "." + string(options.StringsFormat)
+				exportPath := filepath.Join(targetDirectory, exportName)
+				exports = append(exports, "")
+				output.Printf("Found strings %s ... ", exportName)
 
-			if err := ensureDir(filepath.Dir(filePath)); err != nil {
-				fmt.Printf("Warn: Failed to create target directory error: %s\n", err)
-				break
+				resource, err := game_data.StringsResourceFromBytesWithOrder(data, order)
+				if err != nil {
+					output.Printf("invalid: %s\n", err)
+					continue
+				}
+				output.Printf("valid (strings: %d)\n", len(resource.Strings))
+				// archive must be restored from manifest as is
+				if options.Manifest {
+					encoded, err := resource.ToBytes()
+					if err != nil || !bytes.Equal(encoded, data) {
+						output.Printf("Warn: Strings %s can't be encoded back, skipping export\n", exportName)
+						continue
+					}
+				}
+
+				if err := ensureDir(filepath.Dir(exportPath)); err != nil {
+					output.Printf("Warn: Failed to create target directory error: %s\n", err)
+					break
+				}
+				if err := output.claim(exportPath, func() error {
+					return exportStrings(exportPath, resource, options.StringsFormat)
+				}); err != nil {
+					output.Printf("Warn: Failed to write target file: %s\n", err)
+					continue
+				}
+				exports[j] = filepath.ToSlash(exportName)
+				written = resourceName
 			}
+			if written != "" {
+				entryBuffers.Strings = exports
+			}
+		}
+		if !raw {
+			return nil
+		}
 
-			file, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC, 0666)
+		extension := typeName(entry.GetType(), db)
+		filename += "." + extension
+		// script may share the same name
+		if usedNames[filename] {
+			filename = fmt.Sprintf("%s.%016X", filename, entry.GetName())
+		}
+		usedNames[filename] = true
+		written = filename
+		filePath := filepath.Join(targetDirectory, filename)
+		output.Printf("Found %s file %s\n", extension, filename)
+
+		if err := ensureDir(filepath.Dir(filePath)); err != nil {
+			output.Printf("Warn: Failed to create target directory error: %s\n", err)
+			return nil
+		}
+
+		if readers.Variants != nil {
+			for j, variant := range readers.Variants {
+				variantName := variantFileName(filename, extension, j)
+				if err := output.writeFile(filepath.Join(targetDirectory, variantName), variant); err != nil {
+					output.Printf("Warn: Failed to write target variant file: %s\n", err)
+				}
+				// variants may share reader, skipped one must be consumed
+				if _, err := io.Copy(io.Discard, variant); err != nil {
+					return err
+				}
+				entryBuffers.Variants = append(entryBuffers.Variants, filepath.ToSlash(variantName))
+			}
+			for j, stream := range readers.VariantStreams {
+				if stream == nil {
+					entryBuffers.VariantStreams = append(entryBuffers.VariantStreams, "")
+					continue
+				}
+				streamName := variantFileName(filename, extension, j) + hd2.StreamExtension
+				if err := output.writeFile(filepath.Join(targetDirectory, streamName), stream); err != nil {
+					output.Printf("Warn: Failed to write target variant stream file: %s\n", err)
+				}
+				entryBuffers.VariantStreams = append(entryBuffers.VariantStreams, filepath.ToSlash(streamName))
+			}
+		} else {
+			if err := output.writeFile(filePath, readers.Inline); err != nil {
+				output.Printf("Warn: Failed to write target file: %s\n", err)
+			}
+			entryBuffers.Inline = filepath.ToSlash(filename)
+		}
+		if readers.Stream != nil {
+			if err := output.writeFile(filePath+hd2.StreamExtension, readers.Stream); err != nil {
+				output.Printf("Warn: Failed to write target stream file: %s\n", err)
+			}
+			entryBuffers.Stream = filepath.ToSlash(filename + hd2.StreamExtension)
+		}
+		if readers.Gpu != nil {
+			if err := output.writeFile(filePath+hd2.GpuResourcesExtension, readers.Gpu); err != nil {
+				output.Printf("Warn: Failed to write target gpu resources file: %s\n", err)
+			}
+			entryBuffers.Gpu = filepath.ToSlash(filename + hd2.GpuResourcesExtension)
+		}
+		return nil
+	}
+
+	layered := options.Patches == reader.PatchesOverlay && !options.Manifest
+	archive, err := walkArchive(name, layered, opened, unpack)
+	if err != nil {
+		return errors.Join(
+			fmt.Errorf("failed to read archive"),
+			err,
+		)
+	}
+
+	if options.Manifest {
+		archiveManifest, err := manifest.New(archive, func(index int) manifest.Buffers {
+			return buffers[index]
+		})
+		if err != nil {
+			return errors.Join(
+				fmt.Errorf("failed to describe archive"),
+				err,
+			)
+		}
+		if archiveManifest.HD1 != nil {
+			// compressed chunks can't be reproduced, so pack reuses original ones
+			path, err := filepath.Abs(name)
 			if err != nil {
-				fmt.Printf("Warn: Failed to open target file: %s\n", err)
-				break
+				return err
 			}
-			defer file.Close()
-			file.Write(entry.GetInlineBuffer())
+			original, err := manifest.HD1OriginalFromFile(path)
+			if err != nil {
+				return errors.Join(
+					fmt.Errorf("failed to read archive chunks"),
+					err,
+				)
+			}
+			archiveManifest.HD1.Original = original
+		}
+		manifestName := filepath.Join(targetDirectory, filepath.Base(name)+manifestExtension)
+		if err := output.claim(manifestName, func() error {
+			return archiveManifest.SaveToFile(manifestName)
+		}); err != nil {
+			return err
 		}
+		output.Printf("Saved manifest %s\n", manifestName)
 	}
 	return nil
 }
 
+// entryReaders are readers of archive file buffers, stream and gpu readers are
+// nil if file has no such buffers.
+type entryReaders struct {
+	Inline io.Reader
+	Stream io.Reader
+	Gpu    io.Reader
+	// Variants are readers of inline buffer variants, nil unless file has
+	// several ones. Either Inline or Variants may be read.
+	Variants []io.Reader
+	// VariantStreams are readers of stream buffers of Variants, nil if file
+	// has no stream buffers, reader is nil for variant without one. Stream is
+	// nil if they are set.
+	VariantStreams []io.Reader
+	// StreamErr is set if file has stream buffers, but they are not available,
+	// stream readers are nil then.
+	StreamErr error
+}
+
+// readInlineBuffers reads inline buffer or variants into memory, readers are
+// replaced to read them again.
+func readInlineBuffers(readers *entryReaders) ([][]byte, error) {
+	if readers.Variants == nil {
+		data, err := io.ReadAll(readers.Inline)
+		if err != nil {
+			return nil, err
+		}
+		readers.Inline = bytes.NewReader(data)
+		return [][]byte{data}, nil
+	}
+	variants := make([][]byte, len(readers.Variants))
+	for j, variant := range readers.Variants {
+		data, err := io.ReadAll(variant)
+		if err != nil {
+			return nil, err
+		}
+		readers.Variants[j] = bytes.NewReader(data)
+		variants[j] = data
+	}
+	return variants, nil
+}
+
+// variantFileName returns [name].variant[N].[type] file name of variant.
+func variantFileName(filename string, extension string, variant int) string {
+	name, ok := strings.CutSuffix(filename, "."+extension)
+	if !ok {
+		return fmt.Sprintf("%s.variant%d", filename, variant)
+	}
+	return fmt.Sprintf("%s.variant%d.%s", name, variant, extension)
+}
+
+// walkArchive calls fn for each file of archive with path of archive file
+// holding it (patch layer or archive itself), file index in that archive and
+// readers of its buffers, which are valid until fn returns. Buffers are streamed from source files, so
+// memory use doesn't depend on archive size: archives of versions with a
+// registered walker (HD1) are read sequentially, other archives are read
+// lazily. Archive with patch layers to overlay is never walked sequentially.
+// opened is called before fn with archive version, byte order and number of
+// applied patch layers. Returned archive has tables only and may be used to
+// describe archive after walk.
+func walkArchive(
+	name string,
+	layered bool,
+	opened func(version game_data.ArchiveVersion, order binary.ByteOrder, patches int),
+	fn func(entry game_data.File, source string, index int, readers entryReaders) error,
+) (game_data.Archive, error) {
+	sequential := true
+	if layered {
+		// errors are reported by layered archive reader
+		patches, err := reader.PatchPaths(name)
+		sequential = err == nil && len(patches) == 0
+	}
+	if sequential {
+		archive, ok, err := reader.WalkArchiveFile(name, func(version game_data.ArchiveVersion, order binary.ByteOrder) {
+			opened(version, order, 0)
+		}, func(index int, entry game_data.File, data io.Reader) error {
+			return fn(entry, name, index, walkedReaders(entry, data))
+		})
+		if ok || err != nil {
+			return archive, err
+		}
+	}
+
+	var archiveFile *reader.ArchiveFile
+	var err error
+	if layered {
+		archiveFile, err = reader.OpenLayeredArchive(name)
+	} else {
+		archiveFile, err = reader.OpenArchive(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer archiveFile.Close()
+
+	archive := archiveFile.Archive
+	overlay, _ := archive.(*game_data.Overlay)
+	sources := []string{name}
+	if overlay != nil {
+		// layers are opened in the same order
+		patches, err := reader.PatchPaths(name)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, patches...)
+	}
+	opened(archive.GetVersion(), game_data.ByteOrder(archive), len(sources)-1)
+	for i, entry := range archive.GetFiles() {
+		source, index := name, i
+		if overlay != nil {
+			var layer int
+			_, layer, index, _ = overlay.Resolve(entry.GetName(), entry.GetType())
+			source = sources[layer]
+		}
+		readers, err := fileReaders(entry)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to read file %016X.%s", entry.GetName(), entry.GetType()), err)
+		}
+		if err := fn(entry, source, index, readers); err != nil {
+			return nil, err
+		}
+	}
+	return archive, nil
+}
+
+// walkedReaders returns readers of entry buffers of sequentially walked
+// archive, data is reader of its inline buffer.
+func walkedReaders(entry game_data.File, data io.Reader) entryReaders {
+	readers := entryReaders{Inline: data}
+	file, ok := entry.(hd1.File)
+	if !ok {
+		return readers
+	}
+	streams := file.VariantStreamReaders()
+	if streams == nil && file.StreamSize() > 0 {
+		readers.StreamErr = hd1.ErrStreamUnavailable
+	}
+	if len(file.VariantHeaders) > 1 {
+		// variants are read in order from shared data
+		for _, variant := range file.VariantHeaders {
+			readers.Variants = append(readers.Variants, io.LimitReader(data, int64(variant.Size)))
+		}
+		readers.VariantStreams = streams
+		for j, variant := range file.VariantHeaders {
+			if streams != nil && variant.StreamSize == 0 {
+				streams[j] = nil
+			}
+		}
+	} else if streams != nil {
+		readers.Stream = io.MultiReader(streams...)
+	}
+	return readers
+}
+
+// fileReaders returns readers of entry buffers, streamed if entry supports it.
+func fileReaders(entry game_data.File) (entryReaders, error) {
+	if entry, ok := entry.(game_data.StreamingFile); ok {
+		return entryReaders{
+			Inline: entry.InlineReader(),
+			Stream: entry.StreamReader(),
+			Gpu:    entry.GpuReader(),
+		}, nil
+	}
+	inline, err := game_data.ReadInlineBuffer(entry)
+	if err != nil {
+		return entryReaders{}, errors.Join(fmt.Errorf("failed to read inline buffer"), err)
+	}
+	readers := entryReaders{Inline: bytes.NewReader(inline)}
+	if entry, ok := entry.(game_data.VariantFile); ok {
+		if variants := entry.GetVariants(); len(variants) > 1 {
+			for _, variant := range variants {
+				readers.Variants = append(readers.Variants, bytes.NewReader(variant))
+			}
+			streams, err := game_data.ReadVariantStreams(entry)
+			if errors.Is(err, hd1.ErrStreamUnavailable) {
+				readers.StreamErr = err
+			} else if err != nil {
+				return entryReaders{}, errors.Join(fmt.Errorf("failed to read variant stream buffers"), err)
+			}
+			for j, stream := range streams {
+				if len(stream) == 0 {
+					continue
+				}
+				if readers.VariantStreams == nil {
+					readers.VariantStreams = make([]io.Reader, len(variants))
+				}
+				readers.VariantStreams[j] = bytes.NewReader(stream)
+			}
+			// variant files have no other buffers
+			return readers, nil
+		}
+	}
+	stream, err := game_data.ReadStreamBuffer(entry)
+	if errors.Is(err, hd1.ErrStreamUnavailable) {
+		readers.StreamErr = err
+	} else if err != nil {
+		return entryReaders{}, errors.Join(fmt.Errorf("failed to read stream buffer"), err)
+	}
+	if len(stream) > 0 {
+		readers.Stream = bytes.NewReader(stream)
+	}
+	gpu, err := game_data.ReadGpuBuffer(entry)
+	if err != nil {
+		return entryReaders{}, errors.Join(fmt.Errorf("failed to read gpu buffer"), err)
+	}
+	if len(gpu) > 0 {
+		readers.Gpu = bytes.NewReader(gpu)
+	}
+	return readers, nil
+}
+
+// unpackOutput routes logs and files of a single archive unpack.
+type unpackOutput struct {
+	log io.Writer
+	// shared between parallel unpacks, nil if archive is unpacked alone
+	claims *outputClaims
+	// archive order in sequential unpack
+	order int
+}
+
+func (output *unpackOutput) Printf(format string, a ...any) {
+	fmt.Fprintf(output.log, format, a...)
+}
+
+func (output *unpackOutput) writeBuffer(name string, data []byte) error {
+	return output.claim(name, func() error {
+		return writeBuffer(name, data)
+	})
+}
+
+func (output *unpackOutput) writeFile(name string, r io.Reader) error {
+	return output.claim(name, func() error {
+		return writeFile(name, r)
+	})
+}
+
+func (output *unpackOutput) claim(name string, write func() error) error {
+	if output.claims == nil {
+		return write()
+	}
+	return output.claims.write(name, output.order, write)
+}
+
+// outputClaims keeps result of parallel unpack the same as of sequential one:
+// file written by several archives keeps contents from the last archive.
+type outputClaims struct {
+	mu    sync.Mutex
+	files map[string]*outputClaim
+}
+
+type outputClaim struct {
+	mu    sync.Mutex
+	order int
+}
+
+func newOutputClaims() *outputClaims {
+	return &outputClaims{
+		files: map[string]*outputClaim{},
+	}
+}
+
+func (claims *outputClaims) write(name string, order int, write func() error) error {
+	claims.mu.Lock()
+	claim, ok := claims.files[name]
+	if !ok {
+		claim = &outputClaim{order: -1}
+		claims.files[name] = claim
+	}
+	claims.mu.Unlock()
+
+	claim.mu.Lock()
+	defer claim.mu.Unlock()
+	if claim.order > order {
+		return nil
+	}
+	claim.order = order
+	return write()
+}
+
+func writeBuffer(name string, data []byte) error {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(data)
+	return err
+}
+
+// writeFile copies r to file, closing it right after.
+func writeFile(name string, r io.Reader) error {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, r)
+	return errors.Join(err, file.Close())
+}
+
 func ensureDir(dirName string) error {
 	err := os.MkdirAll(dirName, os.ModeDir)
 
@@ -188,7 +765,17 @@ func init() {
 	rootCmd.AddCommand(unpackCmd)
 	unpackCmd.Flags().String("hash-db", "", "Hash DB file.")
 	unpackCmd.Flags().Bool("unknown", false, "Save raw buffer for unknown file formats")
+	unpackCmd.Flags().Bool("manifest", false, "Save all files and manifest to rebuild archive with pack")
+	addPatchesFlag(unpackCmd)
+	addFilterFlags(unpackCmd)
+	unpackCmd.Flags().Bool("metadata", false, "Save metadata ([file]"+metadataExtension+") of every unpacked file")
+	unpackCmd.Flags().String("strings-format", string(game_data.StringsFormatJSON), "Format of exported strings resources: json or csv")
 	rootCmd.AddCommand(unpackAllCmd)
 	unpackAllCmd.Flags().String("hash-db", "", "Hash DB file.")
 	unpackAllCmd.Flags().Bool("unknown", false, "Save raw buffer for unknown file formats")
+	unpackAllCmd.Flags().Bool("manifest", false, "Save all files and manifests to rebuild archives with pack")
+	addScanFlags(unpackAllCmd)
+	addFilterFlags(unpackAllCmd)
+	unpackAllCmd.Flags().Bool("metadata", false, "Save metadata ([file]"+metadataExtension+") of every unpacked file")
+	unpackAllCmd.Flags().String("strings-format", string(game_data.StringsFormatJSON), "Format of exported strings resources: json or csv")
 }
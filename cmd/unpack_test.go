@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd1"
+	"github.com/Zekfad/hd-tool/game_data/writer/writer_hd1"
+)
+
+// TestUnpackScriptVariants checks that script with variants, which can't be
+// decoded as a single script, is saved raw per variant without any flags.
+func TestUnpackScriptVariants(t *testing.T) {
+	directory := t.TempDir()
+	archive := hd1.NewArchive()
+	variants := [][]byte{[]byte("script en"), []byte("script de")}
+	if err := archive.AddFile(1, game_data.Type_lua, game_data.FileData{Variants: variants}); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(directory, "archive")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer_hd1.WriteArchive(*archive, file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(directory, "unpacked")
+	options := unpackOptions{StringsFormat: game_data.StringsFormatJSON}
+	if err := unpackFile(name, target, options, &unpackOutput{log: io.Discard}); err != nil {
+		t.Fatal(err)
+	}
+	for i, variant := range variants {
+		variantName := variantFileName("0000000000000001.lua", "lua", i)
+		data, err := os.ReadFile(filepath.Join(target, variantName))
+		if err != nil {
+			t.Fatalf("variant %d is not unpacked: %s", i, err)
+		}
+		if string(data) != string(variant) {
+			t.Errorf("got variant %d %q, want %q", i, data, variant)
+		}
+	}
+}
+
+// TestUnpackStringsStream checks that strings resource with stream buffer is
+// saved raw without any flags.
+func TestUnpackStringsStream(t *testing.T) {
+	directory := t.TempDir()
+	archive := hd1.NewArchive()
+	data := game_data.FileData{Inline: []byte("strings"), Stream: []byte("stream")}
+	if err := archive.AddFile(1, game_data.Type_strings, data); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(directory, "archive")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	stream, err := os.Create(name + hd1.StreamExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if err := writer_hd1.WriteArchiveWithStreams(*archive, file, stream); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(directory, "unpacked")
+	options := unpackOptions{StringsFormat: game_data.StringsFormatJSON}
+	if err := unpackFile(name, target, options, &unpackOutput{log: io.Discard}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string][]byte{
+		"0000000000000001.strings":        data.Inline,
+		"0000000000000001.strings.stream": data.Stream,
+	} {
+		got, err := os.ReadFile(filepath.Join(target, name))
+		if err != nil {
+			t.Fatalf("%s is not unpacked: %s", name, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("got %s %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestPackManifestReusesChunks checks that archive unpacked with manifest is
+// packed byte to byte from chunks of original archive, without keeping a copy
+// of it in unpacked directory.
+func TestPackManifestReusesChunks(t *testing.T) {
+	directory := t.TempDir()
+	archive := hd1.NewArchive()
+	for name, data := range [][]byte{
+		bytes.Repeat([]byte("large"), hd1.CompressedChunkSize/2),
+		[]byte("small"),
+	} {
+		if err := archive.AddFile(game_data.NameHash(name), 0x1234, game_data.FileData{Inline: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	written := new(bytes.Buffer)
+	if err := writer_hd1.WriteArchive(*archive, written); err != nil {
+		t.Fatal(err)
+	}
+	// chunks compressed differently from writer can only be reused
+	original := recompress(t, written.Bytes(), zlib.BestSpeed)
+	name := filepath.Join(directory, "archive")
+	if err := os.WriteFile(name, original, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(directory, "unpacked")
+	options := unpackOptions{Manifest: true, StringsFormat: game_data.StringsFormatJSON}
+	if err := unpackFile(name, target, options, &unpackOutput{log: io.Discard}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if data, _ := os.ReadFile(filepath.Join(target, entry.Name())); bytes.Equal(data, original) {
+			t.Errorf("unpacked directory holds copy of archive %s", entry.Name())
+		}
+	}
+
+	manifestName := filepath.Join(target, "archive"+manifestExtension)
+	packed := filepath.Join(directory, "packed")
+	if err := packManifest(manifestName, target, packed, ""); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(packed); err != nil || !bytes.Equal(data, original) {
+		t.Errorf("packed archive is not byte-identical (%v)", err)
+	}
+
+	// without original archive chunks are compressed again
+	if err := packManifest(manifestName, target, packed, filepath.Join(directory, "missing")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, written.Bytes()) {
+		t.Error("archive packed without original differs from written one")
+	}
+}
+
+// recompress compresses chunks of HD1 archive with given level.
+func recompress(t *testing.T, data []byte, level int) []byte {
+	archive, err := hd1.PackedArchiveFromReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := archive.GetByteOrder()
+	b := new(bytes.Buffer)
+	binary.Write(b, order, archive.PackedHeader)
+	for _, chunk := range archive.Chunks {
+		inflated, err := chunk.Inflate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed := new(bytes.Buffer)
+		z, _ := zlib.NewWriterLevel(compressed, level)
+		z.Write(inflated)
+		z.Close()
+		binary.Write(b, order, uint32(compressed.Len()))
+		b.Write(compressed.Bytes())
+	}
+	return b.Bytes()
+}
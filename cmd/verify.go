@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/reader"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [folder or archive]",
+	Short: "Verify game archives integrity",
+	Long: `Parse every package and check its structural invariants.
+
+HD1: unpacked size, reserved field, entries count and chunk sizes.
+HD2: type counts, buffer alignments, overlapping buffers and buffer sizes.
+
+Every violation is reported with its offset. Exit status is 1 if any package
+is broken or has violations.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		options, err := scanOptionsFromFlags(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		// every patch layer is a file on its own
+		if !cmd.Flags().Changed("patches") {
+			options.Patches = reader.PatchesSeparate
+		}
+
+		stat, err := os.Stat(name)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		var summary scanSummary
+		var invalid int
+		verify := func(result reader.ScanResult) {
+			if !summary.add(result) {
+				return
+			}
+			archive, ok := result.Archive.(game_data.VerifiableArchive)
+			if !ok {
+				fmt.Printf("Warn: %s: verification of %T is not supported\n", result.Path, result.Archive)
+				return
+			}
+			violations := archive.Verify()
+			if len(violations) > 0 {
+				invalid++
+			}
+			for _, violation := range violations {
+				fmt.Printf("%s: %s\n", result.Path, violation)
+			}
+		}
+
+		if stat.IsDir() {
+			for result := range reader.ArchivesFromDirectory(name, options) {
+				verify(result)
+			}
+		} else {
+			archive, err := reader.OpenArchive(name)
+			if err != nil {
+				verify(reader.ScanResult{Path: name, Err: err})
+			} else {
+				verify(reader.ScanResult{Path: name, Archive: archive.Archive})
+				archive.Close()
+			}
+		}
+
+		summary.Print(os.Stdout)
+		fmt.Printf("%d packages have violations\n", invalid)
+		if invalid > 0 || len(summary.broken) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	addScanFlags(verifyCmd)
+}
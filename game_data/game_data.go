@@ -1,9 +1,20 @@
 package game_data
 
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
 type ArchiveVersion uint32
 
 const (
 	ArchiveVersionHD1 ArchiveVersion = 0xf0000004
+	// Warhammer: Vermintide 2, HD1 layout with uint32 flags after each type
+	// table entry. Layout follows community notes on Vermintide 2 bundles and
+	// wasn't checked against game files, so support is experimental and
+	// read-only, see hd1.HasTypeFlags.
+	ArchiveVersionVT2 ArchiveVersion = 0xf0000005
 	ArchiveVersionHD2 ArchiveVersion = 0xf0000011
 )
 
@@ -66,10 +77,88 @@ type Type interface {
 	GetType() TypeHash
 }
 
+// CountedType is a type table entry that holds number of files of the type.
+type CountedType interface {
+	Type
+	// WithCount returns copy of type with number of files replaced.
+	WithCount(count uint32) Type
+}
+
 type File interface {
 	GetName() NameHash
 	GetType() TypeHash
 	GetInlineBuffer() []byte
+	GetStreamBuffer() []byte
+	GetGpuBuffer() []byte
+}
+
+// StreamingFile is a file that can read its buffers without loading them into
+// memory.
+type StreamingFile interface {
+	File
+	// InlineReader returns reader of inline buffer, never nil.
+	InlineReader() io.Reader
+	// StreamReader returns reader of stream buffer, nil if there is none.
+	StreamReader() io.Reader
+	// GpuReader returns reader of gpu buffer, nil if there is none.
+	GpuReader() io.Reader
+}
+
+// ReadingFile is a file that reads its buffers from archive sources on demand.
+// [File] getters return nil buffer if it can't be read, these methods report
+// read errors instead.
+type ReadingFile interface {
+	File
+	ReadInlineBuffer() ([]byte, error)
+	ReadStreamBuffer() ([]byte, error)
+	ReadGpuBuffer() ([]byte, error)
+}
+
+// ReadInlineBuffer returns inline buffer of file, read error is reported if
+// file is [ReadingFile].
+func ReadInlineBuffer(file File) ([]byte, error) {
+	if file, ok := file.(ReadingFile); ok {
+		return file.ReadInlineBuffer()
+	}
+	return file.GetInlineBuffer(), nil
+}
+
+// ReadStreamBuffer returns stream buffer of file, read error is reported if
+// file is [ReadingFile].
+func ReadStreamBuffer(file File) ([]byte, error) {
+	if file, ok := file.(ReadingFile); ok {
+		return file.ReadStreamBuffer()
+	}
+	return file.GetStreamBuffer(), nil
+}
+
+// ReadGpuBuffer returns gpu buffer of file, read error is reported if file is
+// [ReadingFile].
+func ReadGpuBuffer(file File) ([]byte, error) {
+	if file, ok := file.(ReadingFile); ok {
+		return file.ReadGpuBuffer()
+	}
+	return file.GetGpuBuffer(), nil
+}
+
+// VariantFile is a file with inline buffer split into variants (e.g. HD1
+// localized resources), [File.GetInlineBuffer] returns variants joined.
+type VariantFile interface {
+	File
+	// GetVariants returns inline buffer of each variant.
+	GetVariants() [][]byte
+	// GetVariantStreams returns stream buffer of each variant, nil if file has
+	// no stream buffers. [File.GetStreamBuffer] returns them joined.
+	GetVariantStreams() [][]byte
+}
+
+// ReadVariantStreams returns stream buffer of each variant of file, read error
+// is reported if file has ReadVariantStreams method.
+func ReadVariantStreams(file VariantFile) ([][]byte, error) {
+	if file, ok := file.(interface{ ReadVariantStreams() ([][]byte, error) }); ok {
+		return file.ReadVariantStreams()
+	}
+	return file.GetVariantStreams(), nil
 }
 
 type Archive interface {
@@ -78,3 +167,49 @@ type Archive interface {
 	GetTypes() []Type
 	GetFiles() []File
 }
+
+// CompanionArchive is an archive that stores part of file buffers in companion
+// files located next to the main file (e.g. archive.stream).
+type CompanionArchive interface {
+	Archive
+	// GetCompanionExtensions returns extensions appended to main file name to
+	// get companion file names.
+	GetCompanionExtensions() []string
+	// SetCompanionSource makes archive read buffers of companion file with given
+	// extension from r on demand.
+	SetCompanionSource(extension string, r io.ReaderAt)
+}
+
+// Violation is a broken structural invariant of archive.
+type Violation struct {
+	// Offset of offending structure or buffer, Message tells which file or
+	// data the offset belongs to if it's not the main archive file.
+	Offset  uint64
+	Message string
+}
+
+func (violation Violation) String() string {
+	return fmt.Sprintf("%#X: %s", violation.Offset, violation.Message)
+}
+
+// ByteOrderArchive is an archive that can be stored in either byte order.
+type ByteOrderArchive interface {
+	Archive
+	GetByteOrder() binary.ByteOrder
+}
+
+// ByteOrder returns byte order of archive, archives are little-endian unless
+// they are [ByteOrderArchive].
+func ByteOrder(archive Archive) binary.ByteOrder {
+	if archive, ok := archive.(ByteOrderArchive); ok {
+		return archive.GetByteOrder()
+	}
+	return binary.LittleEndian
+}
+
+// VerifiableArchive is an archive that can check its structural invariants.
+type VerifiableArchive interface {
+	Archive
+	// Verify returns every found violation, archive is valid if there are none.
+	Verify() []Violation
+}
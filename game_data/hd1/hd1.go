@@ -8,11 +8,31 @@ import (
 	"fmt"
 
 	"io"
+	"slices"
 
 	"github.com/Zekfad/hd-tool/game_data"
 	"github.com/ghostiam/binstruct"
 )
 
+// Versions lists versions sharing HD1 layout. Other Stingray versions (e.g.
+// 0xF0000006 or Darktide bundles) are not supported, as their headers and
+// compression may differ and there are no samples to check them against.
+var Versions = []game_data.ArchiveVersion{
+	game_data.ArchiveVersionHD1,
+	game_data.ArchiveVersionVT2,
+}
+
+// HasTypeFlags reports whether type table entries of given version have flags.
+//
+// In Vermintide 2 bundles type hash and name hash of type table entry are
+// followed by uint32 flags, the rest of layout matches HD1. This comes from
+// community notes on the format, there are no game files to check it against
+// in this repository, so flags are never interpreted and such archives are
+// only read, writers reject them.
+func HasTypeFlags(version game_data.ArchiveVersion) bool {
+	return version == game_data.ArchiveVersionVT2
+}
+
 /**
  * Packed container
  */
@@ -20,14 +40,38 @@ import (
 const CompressedChunkSize = 65536
 
 type Archive struct {
+	PackedHeader
+	Chunks []PackedChunk `bin:"ReadChunks"`
+
+	Unpacked UnpackedArchive `bin:"UnpackArchive"`
+
+	// detected from version, console archives are big-endian
+	ByteOrder binary.ByteOrder `bin:"-"`
+
+	stream *streamSource `bin:"-"`
+}
+
+// PackedHeader precedes packed chunks.
+type PackedHeader struct {
 	ArchiveVersion game_data.ArchiveVersion `bin:"ReadVersion"`
 	UnpackedSize   uint32
-	Reserved       uint32        // must be 0
-	Chunks         []PackedChunk `bin:"ReadChunks"`
+	Reserved       uint32 // must be 0
+}
 
-	Unpacked UnpackedArchive `bin:"UnpackArchive"`
+// streamSource is shared by archive and all of its files to read stream
+// buffers on demand.
+type streamSource struct {
+	r io.ReaderAt
 }
 
+// StreamExtension is appended to archive file name to get name of file holding
+// stream buffers.
+const StreamExtension = ".stream"
+
+// ErrStreamUnavailable is returned when stream buffers of a file aren't loaded
+// and archive has no stream source (e.g. stream file is missing).
+var ErrStreamUnavailable = errors.New("stream buffers are not available")
+
 type PackedChunk struct {
 	Size uint32
 	Data []byte `bin:"len:Size"`
@@ -37,20 +81,32 @@ func (chunk PackedChunk) IsCompressed() bool {
 	return chunk.Size != CompressedChunkSize
 }
 
+// Inflate returns unpacked chunk data.
+func (chunk PackedChunk) Inflate() ([]byte, error) {
+	if !chunk.IsCompressed() {
+		return chunk.Data, nil
+	}
+	z, err := zlib.NewReader(bytes.NewReader(chunk.Data))
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	return io.ReadAll(z)
+}
+
 func (data *Archive) ReadChunks(r binstruct.Reader) error {
 	data.Chunks = make([]PackedChunk, 0)
 
-	var chunk PackedChunk
 	for {
+		var chunk PackedChunk
 		err := r.Unmarshal(&chunk)
-		eof := errors.Is(err, io.EOF)
-		if err != nil && !eof {
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
 			return err
 		}
 		data.Chunks = append(data.Chunks, chunk)
-		if eof {
-			break
-		}
 	}
 	return nil
 }
@@ -58,22 +114,14 @@ func (data *Archive) ReadChunks(r binstruct.Reader) error {
 func (archive *Archive) UnpackArchive(r binstruct.Reader) error {
 	buffer := make([]byte, 0, archive.UnpackedSize)
 	for _, packed := range archive.Chunks {
-		if packed.IsCompressed() {
-			z, err := zlib.NewReader(bytes.NewReader(packed.Data))
-			if err != nil {
-				return err
-			}
-			defer z.Close()
-			data, err := io.ReadAll(z)
-			if err != nil {
-				return err
-			}
-			buffer = append(buffer, data...)
-		} else {
-			buffer = append(buffer, packed.Data...)
+		data, err := packed.Inflate()
+		if err != nil {
+			return err
 		}
+		buffer = append(buffer, data...)
 	}
-	reader := binstruct.NewReaderFromBytes(buffer, binary.LittleEndian, false)
+	reader := binstruct.NewReaderFromBytes(buffer, archive.GetByteOrder(), false)
+	archive.Unpacked.Version = archive.ArchiveVersion
 	return reader.Unmarshal(&archive.Unpacked)
 }
 
@@ -83,8 +131,11 @@ func (archive *Archive) UnpackArchive(r binstruct.Reader) error {
 
 type UnpackedArchive struct {
 	Header ArchiveHeader
-	Types  []Type `bin:"len:Header.EntriesCount"`
+	Types  []Type `bin:"ReadTypes"`
 	Files  []File `bin:"len:Header.EntriesCount"`
+
+	// archive version, defines type table layout
+	Version game_data.ArchiveVersion `bin:"-"`
 }
 
 type ArchiveHeader struct {
@@ -95,15 +146,30 @@ type ArchiveHeader struct {
 type Type struct {
 	Type game_data.TypeHash
 	Name game_data.NameHash
+	// only present in archives with [HasTypeFlags] version
+	Flags uint32 `json:",omitempty"`
 }
 
 type VariantHeader struct {
-	Unk00      uint32
-	Size       uint32
+	Unk00 uint32
+	Size  uint32
+	// size of variant stream buffer, variant buffers are stored one after
+	// another starting at file StreamOffset
 	StreamSize uint32
 }
 
 type File struct {
+	FileHeader
+	VariantBuffers [][]byte `bin:"ReadBuffers"`
+
+	// loaded from .stream file, nil if not loaded
+	VariantStreamBuffers [][]byte `bin:"-"`
+
+	stream *streamSource `bin:"-"`
+}
+
+// FileHeader is file table entry, it's followed by variant buffers.
+type FileHeader struct {
 	Type game_data.TypeHash
 	Name game_data.NameHash
 
@@ -111,21 +177,101 @@ type File struct {
 	StreamOffset  uint32
 
 	VariantHeaders []VariantHeader `bin:"len:VariantsCount"`
-	VariantBuffers [][]byte        `bin:"ReadBuffers"`
 }
 
-func (header *Archive) ReadVersion(r binstruct.Reader) error {
+// DetectByteOrder detects archive byte order from its version.
+func DetectByteOrder(r io.ReaderAt) (binary.ByteOrder, error) {
+	var header [4]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	if slices.Contains(Versions, game_data.ArchiveVersion(binary.BigEndian.Uint32(header[:]))) {
+		return binary.BigEndian, nil
+	}
+	return binary.LittleEndian, nil
+}
+
+// ArchiveFromReaderAt parses archive from r.
+// All chunks are read and inflated at once, as tables are stored compressed
+// along with file buffers.
+func ArchiveFromReaderAt(r io.ReaderAt, size int64) (*Archive, error) {
+	order, err := DetectByteOrder(r)
+	if err != nil {
+		return nil, err
+	}
+	reader := binstruct.NewReader(io.NewSectionReader(r, 0, size), order, false)
+	archive := &Archive{ByteOrder: order}
+	if err := reader.Unmarshal(archive); err != nil {
+		return nil, err
+	}
+	archive.setStreamSource(&streamSource{})
+	return archive, nil
+}
+
+// PackedArchiveFromReaderAt parses packed header and chunks of archive from r,
+// chunks are not inflated and archive has no tables.
+func PackedArchiveFromReaderAt(r io.ReaderAt, size int64) (*Archive, error) {
+	order, err := DetectByteOrder(r)
+	if err != nil {
+		return nil, err
+	}
+	reader := binstruct.NewReader(io.NewSectionReader(r, 0, size), order, false)
+	archive := &Archive{ByteOrder: order}
+	if err := reader.Unmarshal(&archive.PackedHeader); err != nil {
+		return nil, err
+	}
+	if err := archive.ReadChunks(reader); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func (archive *Archive) setStreamSource(stream *streamSource) {
+	archive.stream = stream
+	for i := range archive.Unpacked.Files {
+		archive.Unpacked.Files[i].stream = stream
+	}
+}
+
+// SetStreamSource makes archive read stream buffers from r on demand.
+func (archive *Archive) SetStreamSource(r io.ReaderAt) {
+	if archive.stream != nil {
+		archive.stream.r = r
+	}
+}
+
+func (header *PackedHeader) ReadVersion(r binstruct.Reader) error {
 	_version, err := r.ReadUint32()
 	if err != nil {
 		return err
 	}
-	switch version := game_data.ArchiveVersion(_version); version {
-	case game_data.ArchiveVersionHD1:
-		header.ArchiveVersion = version
-		return nil
-	default:
+	version := game_data.ArchiveVersion(_version)
+	if !slices.Contains(Versions, version) {
 		return fmt.Errorf("invalid archive version: %#08X", version)
 	}
+	header.ArchiveVersion = version
+	return nil
+}
+
+func (archive *UnpackedArchive) ReadTypes(r binstruct.Reader) error {
+	archive.Types = make([]Type, archive.Header.EntriesCount)
+	for i := range archive.Types {
+		_type := &archive.Types[i]
+		value, err := r.ReadUint64()
+		if err != nil {
+			return err
+		}
+		_type.Type = game_data.TypeHash(value)
+		if _type.Name, err = r.ReadUint64(); err != nil {
+			return err
+		}
+		if HasTypeFlags(archive.Version) {
+			if _type.Flags, err = r.ReadUint32(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (file *File) ReadBuffers(r binstruct.Reader) error {
@@ -138,7 +284,7 @@ func (file *File) ReadBuffers(r binstruct.Reader) error {
 			return err
 		}
 		if read != size {
-			return fmt.Errorf("not enough data to read variant buffer: read %d, expected %d", read, size)
+			return fmt.Errorf("not enough data to read variant buffer: read %d, expected %d: %w", read, size, io.ErrUnexpectedEOF)
 		}
 		file.VariantBuffers[i] = buffer
 	}
@@ -173,22 +319,137 @@ func (file File) GetInlineBuffer() []byte {
 	return bytes.Join(file.VariantBuffers, nil)
 }
 
+// StreamSize returns total size of variant stream buffers.
+func (file File) StreamSize() int64 {
+	var size int64
+	for _, variant := range file.VariantHeaders {
+		size += int64(variant.StreamSize)
+	}
+	return size
+}
+
+// ReadVariantStreams returns stream buffer of each variant, reading them from
+// archive stream source if they are not loaded. Nil is returned if file has no
+// stream buffers, [ErrStreamUnavailable] if they can't be read.
+func (file File) ReadVariantStreams() ([][]byte, error) {
+	if file.VariantStreamBuffers != nil || file.StreamSize() == 0 {
+		return file.VariantStreamBuffers, nil
+	}
+	readers := file.VariantStreamReaders()
+	if readers == nil {
+		return nil, ErrStreamUnavailable
+	}
+	streams := make([][]byte, len(readers))
+	for i, r := range readers {
+		buffer := make([]byte, file.VariantHeaders[i].StreamSize)
+		if _, err := io.ReadFull(r, buffer); err != nil {
+			return nil, err
+		}
+		streams[i] = buffer
+	}
+	return streams, nil
+}
+
+// VariantStreamReaders returns readers of stream buffer of each variant, nil if
+// file has no stream buffers or they can't be read.
+func (file File) VariantStreamReaders() []io.Reader {
+	readers := make([]io.Reader, len(file.VariantHeaders))
+	if file.VariantStreamBuffers != nil {
+		for i, buffer := range file.VariantStreamBuffers {
+			readers[i] = bytes.NewReader(buffer)
+		}
+		return readers
+	}
+	if file.StreamSize() == 0 || file.stream == nil || file.stream.r == nil {
+		return nil
+	}
+	offset := int64(file.StreamOffset)
+	for i, variant := range file.VariantHeaders {
+		readers[i] = game_data.SectionReader(file.stream.r, offset, int64(variant.StreamSize))
+		offset += int64(variant.StreamSize)
+	}
+	return readers
+}
+
+// GetVariants implements VariantFile.
+func (file File) GetVariants() [][]byte {
+	return file.VariantBuffers
+}
+
+// GetVariantStreams implements VariantFile, streams are nil if they can't be
+// read.
+func (file File) GetVariantStreams() [][]byte {
+	streams, _ := file.ReadVariantStreams()
+	return streams
+}
+
+// GetStreamBuffer implements File, buffer is nil if it can't be read.
+func (file File) GetStreamBuffer() []byte {
+	buffer, _ := file.ReadStreamBuffer()
+	return buffer
+}
+
+// GetGpuBuffer implements File.
+func (file File) GetGpuBuffer() []byte {
+	return nil
+}
+
+// ReadInlineBuffer implements ReadingFile.
+func (file File) ReadInlineBuffer() ([]byte, error) {
+	return file.GetInlineBuffer(), nil
+}
+
+// ReadStreamBuffer implements ReadingFile.
+// It returns variant stream buffers joined, see [File.ReadVariantStreams].
+func (file File) ReadStreamBuffer() ([]byte, error) {
+	streams, err := file.ReadVariantStreams()
+	if streams == nil {
+		return nil, err
+	}
+	return bytes.Join(streams, nil), nil
+}
+
+// ReadGpuBuffer implements ReadingFile.
+func (file File) ReadGpuBuffer() ([]byte, error) {
+	return nil, nil
+}
+
 /**
  * Archive interface
  */
 
 // GetVersion implements Archive.
-func (archive Archive) GetVersion() game_data.ArchiveVersion {
+func (archive *Archive) GetVersion() game_data.ArchiveVersion {
 	return archive.ArchiveVersion
 }
 
+// GetByteOrder implements ByteOrderArchive.
+func (archive *Archive) GetByteOrder() binary.ByteOrder {
+	if archive.ByteOrder == nil {
+		return binary.LittleEndian
+	}
+	return archive.ByteOrder
+}
+
+// GetCompanionExtensions implements CompanionArchive.
+func (archive *Archive) GetCompanionExtensions() []string {
+	return []string{StreamExtension}
+}
+
+// SetCompanionSource implements CompanionArchive.
+func (archive *Archive) SetCompanionSource(extension string, r io.ReaderAt) {
+	if extension == StreamExtension {
+		archive.SetStreamSource(r)
+	}
+}
+
 // GetChecksum implements Archive.
-func (archive Archive) GetChecksum() uint32 {
+func (archive *Archive) GetChecksum() uint32 {
 	return 0
 }
 
 // GetTypes implements Archive.
-func (archive Archive) GetTypes() []game_data.Type {
+func (archive *Archive) GetTypes() []game_data.Type {
 	types := make([]game_data.Type, len(archive.Unpacked.Types))
 	for i, d := range archive.Unpacked.Types {
 		types[i] = d
@@ -197,7 +458,7 @@ func (archive Archive) GetTypes() []game_data.Type {
 }
 
 // GetFiles implements Archive.
-func (archive Archive) GetFiles() []game_data.File {
+func (archive *Archive) GetFiles() []game_data.File {
 	files := make([]game_data.File, len(archive.Unpacked.Files))
 	for i, d := range archive.Unpacked.Files {
 		files[i] = d
@@ -0,0 +1,59 @@
+package hd1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/Zekfad/hd-tool/game_data"
+)
+
+func TestArchiveFromReaderAtTypeFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		version game_data.ArchiveVersion
+		order   binary.ByteOrder
+		flags   uint32
+	}{
+		{"HD1", game_data.ArchiveVersionHD1, binary.LittleEndian, 0},
+		{"HD1 big-endian", game_data.ArchiveVersionHD1, binary.BigEndian, 0},
+		{"VT2", game_data.ArchiveVersionVT2, binary.LittleEndian, fixtureFlags},
+		{"VT2 big-endian", game_data.ArchiveVersionVT2, binary.BigEndian, fixtureFlags},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buffer := []byte("data")
+			data, _ := fixtureArchive(test.version, test.order, []fixtureFile{
+				{name: 1, variants: [][]byte{buffer}},
+			})
+			archive, err := ArchiveFromReaderAt(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if archive.ArchiveVersion != test.version || archive.ByteOrder != test.order {
+				t.Fatalf("got version %#08X in %v, want %#08X in %v",
+					archive.ArchiveVersion, archive.ByteOrder, test.version, test.order)
+			}
+			types := archive.Unpacked.Types
+			if len(types) != 1 || types[0].Type != fixtureType || types[0].Name != 1 || types[0].Flags != test.flags {
+				t.Fatalf("got types %+v, want single type with flags %#08X", types, test.flags)
+			}
+			files := archive.Unpacked.Files
+			if len(files) != 1 || files[0].Type != fixtureType || files[0].Name != 1 {
+				t.Fatalf("got files %+v, want single file", files)
+			}
+			if !bytes.Equal(files[0].GetInlineBuffer(), buffer) {
+				t.Fatalf("got buffer %q, want %q", files[0].GetInlineBuffer(), buffer)
+			}
+		})
+	}
+}
+
+func TestArchiveFromReaderAtUnsupportedVersion(t *testing.T) {
+	data, _ := fixtureArchive(0xf0000006, binary.LittleEndian, []fixtureFile{
+		{name: 1, variants: [][]byte{[]byte("data")}},
+	})
+	if _, err := ArchiveFromReaderAt(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Fatal("archive of version 0xF0000006 is decoded")
+	}
+}
@@ -0,0 +1,188 @@
+package hd1
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Zekfad/hd-tool/game_data"
+)
+
+// NewArchive returns empty archive to be filled with [Archive.AddFile].
+func NewArchive() *Archive {
+	return &Archive{
+		PackedHeader: PackedHeader{
+			ArchiveVersion: game_data.ArchiveVersionHD1,
+		},
+		Unpacked: UnpackedArchive{
+			Header: ArchiveHeader{
+				Magic: make([]byte, 256),
+			},
+		},
+	}
+}
+
+// Mutable returns a copy of archive that can be changed without affecting
+// original one.
+func (archive *Archive) Mutable() game_data.MutableArchive {
+	mutable := *archive
+	mutable.Unpacked.Types = slices.Clone(archive.Unpacked.Types)
+	mutable.Unpacked.Files = slices.Clone(archive.Unpacked.Files)
+	return &mutable
+}
+
+func (archive *Archive) findFile(name game_data.NameHash, _type game_data.TypeHash) int {
+	return slices.IndexFunc(archive.Unpacked.Files, func(file File) bool {
+		return file.Name == name && file.Type == _type
+	})
+}
+
+func checkFileData(data game_data.FileData) error {
+	if len(data.Gpu) > 0 {
+		return fmt.Errorf("HD1 archive has no GPU buffers")
+	}
+	if data.Inline != nil && data.Variants != nil {
+		return fmt.Errorf("either inline buffer or variants can be set")
+	}
+	if data.Stream != nil && data.VariantStreams != nil {
+		return fmt.Errorf("either stream buffer or variant streams can be set")
+	}
+	return nil
+}
+
+// ReplaceFile implements MutableArchive.
+// Inline and stream buffers replace only files with a single variant, variants
+// are replaced individually.
+func (archive *Archive) ReplaceFile(name game_data.NameHash, _type game_data.TypeHash, data game_data.FileData) error {
+	i := archive.findFile(name, _type)
+	if i < 0 {
+		return game_data.FileNotFoundError(name, _type)
+	}
+	if err := checkFileData(data); err != nil {
+		return err
+	}
+	file := &archive.Unpacked.Files[i]
+	variants, streams := data.Variants, data.VariantStreams
+	if data.Inline != nil || data.Stream != nil {
+		if len(file.VariantBuffers) != 1 {
+			return fmt.Errorf("file with %d variants can't be replaced", len(file.VariantBuffers))
+		}
+		if data.Inline != nil {
+			variants = [][]byte{data.Inline}
+		}
+		if data.Stream != nil {
+			streams = [][]byte{data.Stream}
+		}
+	}
+	if len(variants) > len(file.VariantBuffers) || len(streams) > len(file.VariantBuffers) {
+		return fmt.Errorf("file has %d variants, got %d", len(file.VariantBuffers), max(len(variants), len(streams)))
+	}
+	file.VariantHeaders = slices.Clone(file.VariantHeaders)
+	file.VariantBuffers = slices.Clone(file.VariantBuffers)
+	for j, variant := range variants {
+		if variant == nil {
+			continue
+		}
+		file.VariantHeaders[j].Size = uint32(len(variant))
+		file.VariantBuffers[j] = variant
+	}
+	if streams == nil {
+		return nil
+	}
+	// unchanged stream buffers are loaded to be written along with new ones
+	existing, err := file.ReadVariantStreams()
+	if err != nil {
+		return err
+	}
+	file.VariantStreamBuffers = make([][]byte, len(file.VariantHeaders))
+	for j := range file.VariantStreamBuffers {
+		switch {
+		case j < len(streams) && streams[j] != nil:
+			file.VariantStreamBuffers[j] = streams[j]
+		case existing != nil:
+			file.VariantStreamBuffers[j] = existing[j]
+		default:
+			file.VariantStreamBuffers[j] = []byte{}
+		}
+		file.VariantHeaders[j].StreamSize = uint32(len(file.VariantStreamBuffers[j]))
+	}
+	return nil
+}
+
+// AddFile implements MutableArchive.
+func (archive *Archive) AddFile(name game_data.NameHash, _type game_data.TypeHash, data game_data.FileData) error {
+	if archive.findFile(name, _type) >= 0 {
+		return game_data.FileExistsError(name, _type)
+	}
+	if err := checkFileData(data); err != nil {
+		return err
+	}
+	variants, streams := data.Variants, data.VariantStreams
+	if variants == nil {
+		variants = [][]byte{data.Inline}
+	}
+	if streams == nil {
+		streams = [][]byte{data.Stream}
+	}
+	if len(streams) > len(variants) {
+		return fmt.Errorf("file has %d variants, got %d variant streams", len(variants), len(streams))
+	}
+	file := File{
+		FileHeader: FileHeader{
+			Type:           _type,
+			Name:           name,
+			VariantsCount:  uint32(len(variants)),
+			VariantHeaders: make([]VariantHeader, len(variants)),
+		},
+		VariantBuffers:       make([][]byte, len(variants)),
+		VariantStreamBuffers: make([][]byte, len(variants)),
+	}
+	for j, variant := range variants {
+		if variant == nil {
+			variant = []byte{}
+		}
+		file.VariantHeaders[j].Size = uint32(len(variant))
+		file.VariantBuffers[j] = variant
+	}
+	for j := range file.VariantStreamBuffers {
+		stream := []byte{}
+		if j < len(streams) && streams[j] != nil {
+			stream = streams[j]
+		}
+		file.VariantHeaders[j].StreamSize = uint32(len(stream))
+		file.VariantStreamBuffers[j] = stream
+	}
+	archive.Unpacked.Types = append(archive.Unpacked.Types, Type{
+		Type: _type,
+		Name: name,
+	})
+	archive.Unpacked.Files = append(archive.Unpacked.Files, file)
+	archive.Unpacked.Header.EntriesCount = uint32(len(archive.Unpacked.Files))
+	return nil
+}
+
+// RemoveFile implements MutableArchive.
+func (archive *Archive) RemoveFile(name game_data.NameHash, _type game_data.TypeHash) error {
+	i := archive.findFile(name, _type)
+	if i < 0 {
+		return game_data.FileNotFoundError(name, _type)
+	}
+	// type table mirrors file table
+	archive.Unpacked.Types = slices.Delete(archive.Unpacked.Types, i, i+1)
+	archive.Unpacked.Files = slices.Delete(archive.Unpacked.Files, i, i+1)
+	archive.Unpacked.Header.EntriesCount = uint32(len(archive.Unpacked.Files))
+	return nil
+}
+
+// RenameFile implements MutableArchive.
+func (archive *Archive) RenameFile(name game_data.NameHash, _type game_data.TypeHash, newName game_data.NameHash) error {
+	i := archive.findFile(name, _type)
+	if i < 0 {
+		return game_data.FileNotFoundError(name, _type)
+	}
+	if archive.findFile(newName, _type) >= 0 {
+		return game_data.FileExistsError(newName, _type)
+	}
+	archive.Unpacked.Types[i].Name = newName
+	archive.Unpacked.Files[i].Name = newName
+	return nil
+}
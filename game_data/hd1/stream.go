@@ -0,0 +1,208 @@
+package hd1
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ghostiam/binstruct"
+)
+
+// chunkReader inflates packed chunks one at a time while they are read.
+type chunkReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+
+	// remaining packed data of current chunk
+	packed  *io.LimitedReader
+	current io.Reader
+	z       io.ReadCloser
+}
+
+func newChunkReader(r io.Reader, order binary.ByteOrder) *chunkReader {
+	return &chunkReader{r: r, order: order}
+}
+
+func (chunks *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if chunks.current == nil {
+			if err := chunks.next(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := chunks.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			if err := chunks.finish(); err != nil {
+				return n, err
+			}
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+func (chunks *chunkReader) next() error {
+	var size uint32
+	if err := binary.Read(chunks.r, chunks.order, &size); err != nil {
+		return err
+	}
+	chunks.packed = &io.LimitedReader{R: chunks.r, N: int64(size)}
+	if !(PackedChunk{Size: size}).IsCompressed() {
+		chunks.current = chunks.packed
+		return nil
+	}
+	z, err := zlib.NewReader(chunks.packed)
+	if err != nil {
+		return err
+	}
+	chunks.z = z
+	chunks.current = z
+	return nil
+}
+
+// finish closes current chunk and skips its trailing packed data.
+func (chunks *chunkReader) finish() error {
+	chunks.current = nil
+	if chunks.z != nil {
+		if err := chunks.z.Close(); err != nil {
+			return err
+		}
+		chunks.z = nil
+	}
+	if _, err := io.Copy(io.Discard, chunks.packed); err != nil {
+		return err
+	}
+	if chunks.packed.N > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// WalkArchive reads archive from r sequentially with bounded memory: chunks
+// are inflated one at a time and file buffers are never loaded. fn is called
+// for each file with reader of its joined variant buffers, which is valid
+// until fn returns, unread data is skipped. Stream buffers of files are read
+// from stream on demand, stream may be nil if archive has no stream file.
+// Returned archive has tables only, without chunks and buffers.
+func WalkArchive(r io.Reader, stream io.ReaderAt, fn func(index int, file File, data io.Reader) error) (*Archive, error) {
+	packed := bufio.NewReader(r)
+	version, err := packed.Peek(4)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	order, err := DetectByteOrder(bytesReaderAt(version))
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{
+		ByteOrder: order,
+		stream:    &streamSource{r: stream},
+	}
+	if err := binstruct.NewReader(forwardReader{packed}, order, false).Unmarshal(&archive.PackedHeader); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	unpacked := bufio.NewReader(newChunkReader(packed, order))
+	reader := binstruct.NewReader(forwardReader{unpacked}, order, false)
+	archive.Unpacked.Version = archive.ArchiveVersion
+	if err := reader.Unmarshal(&archive.Unpacked.Header); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if err := archive.Unpacked.ReadTypes(reader); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	archive.Unpacked.Files = make([]File, archive.Unpacked.Header.EntriesCount)
+	for i := range archive.Unpacked.Files {
+		file := &archive.Unpacked.Files[i]
+		file.stream = archive.stream
+		if err := reader.Unmarshal(&file.FileHeader); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		var size int64
+		for _, variant := range file.VariantHeaders {
+			size += int64(variant.Size)
+		}
+
+		data := &io.LimitedReader{R: unpacked, N: size}
+		if err := fn(i, *file, data); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(io.Discard, data); err != nil {
+			return nil, err
+		}
+		if data.N > 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	return archive, nil
+}
+
+// WalkChunks reads packed header and chunks of archive from r sequentially,
+// fn is called for each chunk, chunks are not inflated.
+// Returned archive has packed header only.
+func WalkChunks(r io.Reader, fn func(index int, chunk PackedChunk) error) (*Archive, error) {
+	packed := bufio.NewReader(r)
+	version, err := packed.Peek(4)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	order, err := DetectByteOrder(bytesReaderAt(version))
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{ByteOrder: order}
+	reader := binstruct.NewReader(forwardReader{packed}, order, false)
+	if err := reader.Unmarshal(&archive.PackedHeader); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	for i := 0; ; i++ {
+		if _, err := packed.Peek(1); errors.Is(err, io.EOF) {
+			return archive, nil
+		}
+		var chunk PackedChunk
+		if err := reader.Unmarshal(&chunk); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if err := fn(i, chunk); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// unexpectedEOF reports end of data in the middle of archive as truncation.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// forwardReader adapts sequential reader to binstruct reader, archive structs
+// have no offset tags, so it's never seeked.
+type forwardReader struct {
+	io.Reader
+}
+
+func (forwardReader) Seek(offset int64, whence int) (int64, error) {
+	return 0, fmt.Errorf("sequential reader can't seek: %w", errors.ErrUnsupported)
+}
+
+type bytesReaderAt []byte
+
+func (data bytesReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	if offset >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, data[offset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
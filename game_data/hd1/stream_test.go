@@ -0,0 +1,201 @@
+package hd1
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/Zekfad/hd-tool/game_data"
+)
+
+const (
+	fixtureType  game_data.TypeHash = 0xa14e8dfa2cd117e2
+	fixtureFlags uint32             = 0xdeadbeef
+)
+
+// fixtureFile is file of fixture archive, all files are of fixtureType.
+type fixtureFile struct {
+	name     game_data.NameHash
+	variants [][]byte
+	// stream buffer of each variant, nil if file has no stream buffers
+	streams [][]byte
+}
+
+// fixtureArchive encodes archive of given files by hand, so that decoders are
+// not checked against writer. Type table entries have fixtureFlags if version
+// has type flags. Unpacked data is split into chunks the same way as game does,
+// stream buffers of files are stored one after another.
+func fixtureArchive(version game_data.ArchiveVersion, order binary.ByteOrder, files []fixtureFile) ([]byte, []byte) {
+	unpacked := new(bytes.Buffer)
+	stream := new(bytes.Buffer)
+	binary.Write(unpacked, order, uint32(len(files)))
+	unpacked.Write(make([]byte, 256))
+	for _, file := range files {
+		binary.Write(unpacked, order, uint64(fixtureType))
+		binary.Write(unpacked, order, file.name)
+		if HasTypeFlags(version) {
+			binary.Write(unpacked, order, fixtureFlags)
+		}
+	}
+	for _, file := range files {
+		binary.Write(unpacked, order, uint64(fixtureType))
+		binary.Write(unpacked, order, file.name)
+		binary.Write(unpacked, order, uint32(len(file.variants)))
+		binary.Write(unpacked, order, uint32(stream.Len()))
+		for i, variant := range file.variants {
+			var streamSize int
+			if file.streams != nil {
+				streamSize = len(file.streams[i])
+				stream.Write(file.streams[i])
+			}
+			binary.Write(unpacked, order, uint32(0))
+			binary.Write(unpacked, order, uint32(len(variant)))
+			binary.Write(unpacked, order, uint32(streamSize))
+		}
+		for _, variant := range file.variants {
+			unpacked.Write(variant)
+		}
+	}
+
+	packed := new(bytes.Buffer)
+	binary.Write(packed, order, uint32(version))
+	binary.Write(packed, order, uint32(unpacked.Len()))
+	binary.Write(packed, order, uint32(0))
+	for data := unpacked.Bytes(); len(data) > 0; {
+		chunk := make([]byte, CompressedChunkSize)
+		data = data[copy(chunk, data):]
+		compressed := new(bytes.Buffer)
+		z := zlib.NewWriter(compressed)
+		z.Write(chunk)
+		z.Close()
+		binary.Write(packed, order, uint32(compressed.Len()))
+		packed.Write(compressed.Bytes())
+	}
+	return packed.Bytes(), stream.Bytes()
+}
+
+// walkFixtureFiles spans several chunks, one file crosses chunk boundaries.
+var walkFixtureFiles = []fixtureFile{
+	{name: 1, variants: [][]byte{[]byte("small")}},
+	{name: 2, variants: [][]byte{bytes.Repeat([]byte("large"), CompressedChunkSize/2)}},
+	{
+		name:     3,
+		variants: [][]byte{[]byte("en"), []byte("de"), {}},
+		streams:  [][]byte{[]byte("stream en"), []byte("stream de"), {}},
+	},
+	{name: 4, variants: [][]byte{{}}},
+}
+
+func TestWalkArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		version game_data.ArchiveVersion
+		order   binary.ByteOrder
+	}{
+		{"HD1", game_data.ArchiveVersionHD1, binary.LittleEndian},
+		{"HD1 big-endian", game_data.ArchiveVersionHD1, binary.BigEndian},
+		{"VT2", game_data.ArchiveVersionVT2, binary.LittleEndian},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, stream := fixtureArchive(test.version, test.order, walkFixtureFiles)
+			want, err := ArchiveFromReaderAt(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want.SetStreamSource(bytes.NewReader(stream))
+
+			var buffers [][]byte
+			var streams [][][]byte
+			got, err := WalkArchive(bytes.NewReader(data), bytes.NewReader(stream), func(index int, file File, data io.Reader) error {
+				if index != len(buffers) {
+					t.Fatalf("got file %d, want %d", index, len(buffers))
+				}
+				buffer, err := io.ReadAll(data)
+				if err != nil {
+					return err
+				}
+				variantStreams, err := file.ReadVariantStreams()
+				if err != nil {
+					return err
+				}
+				buffers = append(buffers, buffer)
+				streams = append(streams, variantStreams)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.PackedHeader != want.PackedHeader || got.ByteOrder != want.ByteOrder {
+				t.Fatalf("got header %+v in %v, want %+v in %v", got.PackedHeader, got.ByteOrder, want.PackedHeader, want.ByteOrder)
+			}
+			if !reflect.DeepEqual(got.Unpacked.Header, want.Unpacked.Header) {
+				t.Fatalf("got archive header %+v, want %+v", got.Unpacked.Header, want.Unpacked.Header)
+			}
+			if !reflect.DeepEqual(got.Unpacked.Types, want.Unpacked.Types) {
+				t.Fatalf("got types %+v, want %+v", got.Unpacked.Types, want.Unpacked.Types)
+			}
+			if len(got.Unpacked.Files) != len(want.Unpacked.Files) || len(buffers) != len(want.Unpacked.Files) {
+				t.Fatalf("got %d files, walked %d, want %d", len(got.Unpacked.Files), len(buffers), len(want.Unpacked.Files))
+			}
+			for i, file := range want.Unpacked.Files {
+				if !reflect.DeepEqual(got.Unpacked.Files[i].FileHeader, file.FileHeader) {
+					t.Errorf("file %d: got header %+v, want %+v", i, got.Unpacked.Files[i].FileHeader, file.FileHeader)
+				}
+				if !bytes.Equal(buffers[i], file.GetInlineBuffer()) {
+					t.Errorf("file %d: walked buffer of %d bytes differs from decoded one", i, len(buffers[i]))
+				}
+				wantStreams, err := file.ReadVariantStreams()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(streams[i], wantStreams) {
+					t.Errorf("file %d: got streams %q, want %q", i, streams[i], wantStreams)
+				}
+			}
+		})
+	}
+}
+
+// TestWalkArchiveSkipsUnread checks that buffers not read by fn are skipped.
+func TestWalkArchiveSkipsUnread(t *testing.T) {
+	data, _ := fixtureArchive(game_data.ArchiveVersionHD1, binary.LittleEndian, walkFixtureFiles)
+	var names []game_data.NameHash
+	_, err := WalkArchive(bytes.NewReader(data), nil, func(index int, file File, data io.Reader) error {
+		names = append(names, file.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []game_data.NameHash{1, 2, 3, 4}) {
+		t.Fatalf("got files %v", names)
+	}
+}
+
+func TestWalkArchiveTruncated(t *testing.T) {
+	data, _ := fixtureArchive(game_data.ArchiveVersionHD1, binary.LittleEndian, walkFixtureFiles)
+	data = data[:len(data)/2]
+	_, err := WalkArchive(bytes.NewReader(data), nil, func(index int, file File, data io.Reader) error {
+		_, err := io.Copy(io.Discard, data)
+		return err
+	})
+	if err == nil {
+		t.Fatal("truncated archive is walked")
+	}
+}
+
+func TestWalkArchiveStreamUnavailable(t *testing.T) {
+	data, _ := fixtureArchive(game_data.ArchiveVersionHD1, binary.LittleEndian, walkFixtureFiles)
+	_, err := WalkArchive(bytes.NewReader(data), nil, func(index int, file File, data io.Reader) error {
+		_, err := file.ReadVariantStreams()
+		return err
+	})
+	if err != ErrStreamUnavailable {
+		t.Fatalf("got error %v, want %v", err, ErrStreamUnavailable)
+	}
+}
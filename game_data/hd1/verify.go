@@ -0,0 +1,117 @@
+package hd1
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+
+	"github.com/Zekfad/hd-tool/game_data"
+)
+
+const (
+	// version, unpacked size and reserved fields
+	PackedHeaderSize = 12
+	// entries count and magic
+	HeaderSize        = 4 + 256
+	TypeSize          = 16
+	TypeFlagsSize     = 4
+	FileSize          = 24
+	VariantHeaderSize = 12
+)
+
+// Verify implements VerifiableArchive.
+// Chunk offsets are offsets in archive file, while table and buffer offsets
+// are offsets in unpacked data.
+func (archive *Archive) Verify() []game_data.Violation {
+	var violations []game_data.Violation
+	report := func(offset uint64, format string, a ...any) {
+		violations = append(violations, game_data.Violation{
+			Offset:  offset,
+			Message: fmt.Sprintf(format, a...),
+		})
+	}
+
+	if archive.Reserved != 0 {
+		report(8, "reserved field is %#X, expected 0", archive.Reserved)
+	}
+
+	var inflated uint64
+	offset := uint64(PackedHeaderSize)
+	for i, chunk := range archive.Chunks {
+		size, err := chunkInflatedSize(chunk)
+		switch {
+		case chunk.Size > CompressedChunkSize:
+			report(offset, "chunk %d size %#X exceeds chunk size %#X", i, chunk.Size, CompressedChunkSize)
+		case err != nil:
+			report(offset, "chunk %d can't be inflated: %s", i, err)
+		case size != CompressedChunkSize:
+			report(offset, "chunk %d inflates to %#X bytes, expected %#X", i, size, CompressedChunkSize)
+		}
+		inflated += size
+		offset += 4 + uint64(len(chunk.Data))
+	}
+
+	unpackedSize := uint64(archive.UnpackedSize)
+	expectedChunks := (unpackedSize + CompressedChunkSize - 1) / CompressedChunkSize
+	if uint64(len(archive.Chunks)) != expectedChunks {
+		report(4, "unpacked size %#X requires %d chunks, archive has %d", unpackedSize, expectedChunks, len(archive.Chunks))
+	}
+	if inflated < unpackedSize {
+		report(4, "unpacked size %#X exceeds inflated size %#X", unpackedSize, inflated)
+	}
+
+	unpacked := archive.Unpacked
+	entriesCount := int(unpacked.Header.EntriesCount)
+	if len(unpacked.Types) != entriesCount || len(unpacked.Files) != entriesCount {
+		report(0, "entries count %d doesn't match %d types and %d files (unpacked data)",
+			entriesCount, len(unpacked.Types), len(unpacked.Files))
+	}
+
+	typeSize := uint64(TypeSize)
+	if HasTypeFlags(archive.ArchiveVersion) {
+		typeSize += TypeFlagsSize
+	}
+	offset = HeaderSize
+	for i, _type := range unpacked.Types {
+		if i < len(unpacked.Files) {
+			file := unpacked.Files[i]
+			if _type.Type != file.Type || _type.Name != file.Name {
+				report(offset, "type entry %d (%016X, %016X) doesn't match file (%016X, %016X) (unpacked data)",
+					i, uint64(_type.Type), _type.Name, uint64(file.Type), file.Name)
+			}
+		}
+		offset += typeSize
+	}
+	for i, file := range unpacked.Files {
+		if int(file.VariantsCount) != len(file.VariantHeaders) {
+			report(offset, "file %d variants count %d doesn't match %d variant headers (unpacked data)",
+				i, file.VariantsCount, len(file.VariantHeaders))
+		}
+		offset += FileSize + VariantHeaderSize*uint64(len(file.VariantHeaders))
+		for j, variant := range file.VariantHeaders {
+			if j < len(file.VariantBuffers) && int(variant.Size) != len(file.VariantBuffers[j]) {
+				report(offset, "file %d variant %d size %#X doesn't match buffer of %#X bytes (unpacked data)",
+					i, j, variant.Size, len(file.VariantBuffers[j]))
+			}
+			offset += uint64(variant.Size)
+		}
+	}
+	if offset != unpackedSize {
+		report(4, "unpacked size %#X doesn't match tables and buffers size %#X", unpackedSize, offset)
+	}
+	return violations
+}
+
+func chunkInflatedSize(chunk PackedChunk) (uint64, error) {
+	if !chunk.IsCompressed() {
+		return uint64(len(chunk.Data)), nil
+	}
+	z, err := zlib.NewReader(bytes.NewReader(chunk.Data))
+	if err != nil {
+		return 0, err
+	}
+	defer z.Close()
+	size, err := io.Copy(io.Discard, z)
+	return uint64(size), err
+}
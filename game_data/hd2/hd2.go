@@ -1,7 +1,10 @@
 package hd2
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
 
 	"github.com/Zekfad/hd-tool/game_data"
 	"github.com/ghostiam/binstruct"
@@ -11,6 +14,15 @@ type Archive struct {
 	Header ArchiveHeader
 	Types  []Type `bin:"len:Header.TypesCount"`
 	Files  []File `bin:"len:Header.FilesCount"`
+
+	sources *sources `bin:"-"`
+}
+
+// sources are shared by archive and all of its files to read buffers on demand.
+type sources struct {
+	main   io.ReaderAt
+	stream io.ReaderAt
+	gpu    io.ReaderAt
 }
 
 type ArchiveHeader struct {
@@ -69,7 +81,52 @@ type File struct {
 	GpuAlignment uint32
 	Index        uint32
 
-	InlineBuffer []byte `bin:"offsetStart:Offset, len:Size, offsetRestore"`
+	// loaded on demand if nil
+	InlineBuffer []byte `bin:"-"`
+	// loaded from .stream file
+	StreamBuffer []byte `bin:"-"`
+	// loaded from .gpu_resources file
+	GpuBuffer []byte `bin:"-"`
+
+	sources *sources `bin:"-"`
+}
+
+const (
+	StreamExtension       = ".stream"
+	GpuResourcesExtension = ".gpu_resources"
+)
+
+// ArchiveFromReaderAt parses archive header, type and file tables.
+// Buffers are not loaded, instead they are read from r when requested.
+func ArchiveFromReaderAt(r io.ReaderAt, size int64) (*Archive, error) {
+	reader := binstruct.NewReader(io.NewSectionReader(r, 0, size), binary.LittleEndian, false)
+	archive := &Archive{}
+	if err := reader.Unmarshal(archive); err != nil {
+		return nil, err
+	}
+	archive.sources = &sources{main: r}
+	for i := range archive.Files {
+		file := &archive.Files[i]
+		if end := file.Offset + uint64(file.Size); end > uint64(size) {
+			return nil, fmt.Errorf("inline buffer of file %d is out of bounds: %#X > %#X: %w", i, end, size, io.ErrUnexpectedEOF)
+		}
+		file.sources = archive.sources
+	}
+	return archive, nil
+}
+
+// SetStreamSource makes archive read stream buffers from r on demand.
+func (archive *Archive) SetStreamSource(r io.ReaderAt) {
+	if archive.sources != nil {
+		archive.sources.stream = r
+	}
+}
+
+// SetGpuSource makes archive read gpu buffers from r on demand.
+func (archive *Archive) SetGpuSource(r io.ReaderAt) {
+	if archive.sources != nil {
+		archive.sources.gpu = r
+	}
 }
 
 func (header *ArchiveHeader) ReadVersion(r binstruct.Reader) error {
@@ -86,6 +143,83 @@ func (header *ArchiveHeader) ReadVersion(r binstruct.Reader) error {
 	}
 }
 
+// ReadInlineBuffer implements ReadingFile.
+// It returns inline buffer, reading it from archive source if it is not loaded.
+func (file File) ReadInlineBuffer() ([]byte, error) {
+	if file.InlineBuffer != nil || file.sources == nil {
+		return file.InlineBuffer, nil
+	}
+	return readAt(file.sources.main, file.Offset, file.Size)
+}
+
+// ReadStreamBuffer implements ReadingFile.
+// It returns stream buffer, reading it from archive stream source if it is not
+// loaded.
+func (file File) ReadStreamBuffer() ([]byte, error) {
+	if file.StreamBuffer != nil || file.sources == nil || file.sources.stream == nil {
+		return file.StreamBuffer, nil
+	}
+	return readAt(file.sources.stream, file.StreamOffset, file.StreamSize)
+}
+
+// ReadGpuBuffer implements ReadingFile.
+// It returns gpu buffer, reading it from archive gpu source if it is not
+// loaded.
+func (file File) ReadGpuBuffer() ([]byte, error) {
+	if file.GpuBuffer != nil || file.sources == nil || file.sources.gpu == nil {
+		return file.GpuBuffer, nil
+	}
+	return readAt(file.sources.gpu, file.GpuOffset, file.GpuStreamSize)
+}
+
+// InlineReader implements StreamingFile.
+func (file File) InlineReader() io.Reader {
+	if file.InlineBuffer != nil || file.sources == nil {
+		return bytes.NewReader(file.InlineBuffer)
+	}
+	return sectionReader(file.sources.main, file.Offset, file.Size)
+}
+
+// StreamReader implements StreamingFile.
+func (file File) StreamReader() io.Reader {
+	if file.StreamBuffer != nil || file.sources == nil || file.sources.stream == nil {
+		if len(file.StreamBuffer) == 0 {
+			return nil
+		}
+		return bytes.NewReader(file.StreamBuffer)
+	}
+	if file.StreamSize == 0 {
+		return nil
+	}
+	return sectionReader(file.sources.stream, file.StreamOffset, file.StreamSize)
+}
+
+// GpuReader implements StreamingFile.
+func (file File) GpuReader() io.Reader {
+	if file.GpuBuffer != nil || file.sources == nil || file.sources.gpu == nil {
+		if len(file.GpuBuffer) == 0 {
+			return nil
+		}
+		return bytes.NewReader(file.GpuBuffer)
+	}
+	if file.GpuStreamSize == 0 {
+		return nil
+	}
+	return sectionReader(file.sources.gpu, file.GpuOffset, file.GpuStreamSize)
+}
+
+func sectionReader(r io.ReaderAt, offset uint64, size uint32) io.Reader {
+	return game_data.SectionReader(r, int64(offset), int64(size))
+}
+
+func readAt(r io.ReaderAt, offset uint64, size uint32) ([]byte, error) {
+	buffer := make([]byte, size)
+	if _, err := r.ReadAt(buffer, int64(offset)); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
 /**
  * Type interface
  */
@@ -95,6 +229,12 @@ func (_type Type) GetType() game_data.TypeHash {
 	return _type.Type
 }
 
+// WithCount implements CountedType.
+func (_type Type) WithCount(count uint32) game_data.Type {
+	_type.Count = count
+	return _type
+}
+
 /**
  * File interface
  */
@@ -109,9 +249,22 @@ func (file File) GetType() game_data.TypeHash {
 	return file.Type
 }
 
-// GetInlineBuffer implements File.
+// GetInlineBuffer implements File, buffer is nil if it can't be read.
 func (file File) GetInlineBuffer() []byte {
-	return file.InlineBuffer
+	buffer, _ := file.ReadInlineBuffer()
+	return buffer
+}
+
+// GetStreamBuffer implements File, buffer is nil if it can't be read.
+func (file File) GetStreamBuffer() []byte {
+	buffer, _ := file.ReadStreamBuffer()
+	return buffer
+}
+
+// GetGpuBuffer implements File, buffer is nil if it can't be read.
+func (file File) GetGpuBuffer() []byte {
+	buffer, _ := file.ReadGpuBuffer()
+	return buffer
 }
 
 /**
@@ -119,17 +272,17 @@ func (file File) GetInlineBuffer() []byte {
  */
 
 // GetVersion implements Archive.
-func (archive Archive) GetVersion() game_data.ArchiveVersion {
+func (archive *Archive) GetVersion() game_data.ArchiveVersion {
 	return archive.Header.ArchiveVersion
 }
 
 // GetChecksum implements Archive.
-func (archive Archive) GetChecksum() uint32 {
+func (archive *Archive) GetChecksum() uint32 {
 	return archive.Header.Checksum
 }
 
 // GetTypes implements Archive.
-func (archive Archive) GetTypes() []game_data.Type {
+func (archive *Archive) GetTypes() []game_data.Type {
 	types := make([]game_data.Type, len(archive.Types))
 	for i, d := range archive.Types {
 		types[i] = d
@@ -137,8 +290,23 @@ func (archive Archive) GetTypes() []game_data.Type {
 	return types
 }
 
+// GetCompanionExtensions implements CompanionArchive.
+func (archive *Archive) GetCompanionExtensions() []string {
+	return []string{StreamExtension, GpuResourcesExtension}
+}
+
+// SetCompanionSource implements CompanionArchive.
+func (archive *Archive) SetCompanionSource(extension string, r io.ReaderAt) {
+	switch extension {
+	case StreamExtension:
+		archive.SetStreamSource(r)
+	case GpuResourcesExtension:
+		archive.SetGpuSource(r)
+	}
+}
+
 // GetFiles implements Archive.
-func (archive Archive) GetFiles() []game_data.File {
+func (archive *Archive) GetFiles() []game_data.File {
 	files := make([]game_data.File, len(archive.Files))
 	for i, d := range archive.Files {
 		files[i] = d
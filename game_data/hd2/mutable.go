@@ -0,0 +1,172 @@
+package hd2
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/Zekfad/hd-tool/game_data"
+)
+
+// Alignments of a new type added to archive with empty type table. They aren't
+// taken from game archives, but are conservative guesses (16 bytes for CPU
+// data, 512 bytes for GPU resources) that keep buffers aligned, not packed
+// tightly. Archives with types use their largest alignments instead, see
+// [Archive.AddFile].
+const (
+	DefaultAlignment    = 0x10
+	DefaultGpuAlignment = 0x200
+)
+
+var errVariants = errors.New("HD2 files have no variants")
+
+// NewArchive returns empty archive to be filled with [Archive.AddFile].
+func NewArchive() *Archive {
+	return &Archive{
+		Header: ArchiveHeader{
+			ArchiveVersion: game_data.ArchiveVersionHD2,
+		},
+	}
+}
+
+// Mutable returns a copy of archive that can be changed without affecting
+// original one.
+func (archive *Archive) Mutable() game_data.MutableArchive {
+	mutable := *archive
+	mutable.Types = slices.Clone(archive.Types)
+	mutable.Files = slices.Clone(archive.Files)
+	return &mutable
+}
+
+func (archive *Archive) findFile(name game_data.NameHash, _type game_data.TypeHash) int {
+	return slices.IndexFunc(archive.Files, func(file File) bool {
+		return file.Name == name && file.Type == _type
+	})
+}
+
+// typeAlignments returns largest alignments of types in type table.
+func (archive *Archive) typeAlignments() (uint32, uint32) {
+	if len(archive.Types) == 0 {
+		return DefaultAlignment, DefaultGpuAlignment
+	}
+	var alignment, gpuAlignment uint32
+	for _, t := range archive.Types {
+		alignment = max(alignment, t.Alignment)
+		gpuAlignment = max(gpuAlignment, t.GpuAlignment)
+	}
+	return alignment, gpuAlignment
+}
+
+func (archive *Archive) findType(_type game_data.TypeHash) int {
+	return slices.IndexFunc(archive.Types, func(t Type) bool {
+		return t.Type == _type
+	})
+}
+
+// ReplaceFile implements MutableArchive.
+func (archive *Archive) ReplaceFile(name game_data.NameHash, _type game_data.TypeHash, data game_data.FileData) error {
+	i := archive.findFile(name, _type)
+	if i < 0 {
+		return game_data.FileNotFoundError(name, _type)
+	}
+	if data.Variants != nil || data.VariantStreams != nil {
+		return errVariants
+	}
+	file := &archive.Files[i]
+	if data.Inline != nil {
+		file.InlineBuffer = data.Inline
+		file.Size = uint32(len(data.Inline))
+	}
+	if data.Stream != nil {
+		file.StreamBuffer = data.Stream
+		file.StreamSize = uint32(len(data.Stream))
+	}
+	if data.Gpu != nil {
+		file.GpuBuffer = data.Gpu
+		file.GpuStreamSize = uint32(len(data.Gpu))
+	}
+	return nil
+}
+
+// AddFile implements MutableArchive.
+// File alignments are taken from type table, new type is added with largest
+// alignments of existing types, or default ones if there are none.
+func (archive *Archive) AddFile(name game_data.NameHash, _type game_data.TypeHash, data game_data.FileData) error {
+	if archive.findFile(name, _type) >= 0 {
+		return game_data.FileExistsError(name, _type)
+	}
+	if data.Variants != nil || data.VariantStreams != nil {
+		return errVariants
+	}
+	t := archive.findType(_type)
+	if t < 0 {
+		alignment, gpuAlignment := archive.typeAlignments()
+		archive.Types = append(archive.Types, Type{
+			Type:         _type,
+			Alignment:    alignment,
+			GpuAlignment: gpuAlignment,
+		})
+		t = len(archive.Types) - 1
+	}
+	archive.Types[t].Count++
+
+	var index uint32
+	for _, file := range archive.Files {
+		index = max(index, file.Index+1)
+	}
+	file := File{
+		Name:          name,
+		Type:          _type,
+		Size:          uint32(len(data.Inline)),
+		StreamSize:    uint32(len(data.Stream)),
+		GpuStreamSize: uint32(len(data.Gpu)),
+		Alignment:     archive.Types[t].Alignment,
+		GpuAlignment:  archive.Types[t].GpuAlignment,
+		Index:         index,
+		InlineBuffer:  data.Inline,
+		StreamBuffer:  data.Stream,
+		GpuBuffer:     data.Gpu,
+	}
+	if file.InlineBuffer == nil {
+		file.InlineBuffer = []byte{}
+	}
+	archive.Files = append(archive.Files, file)
+	archive.updateCounts()
+	return nil
+}
+
+// RemoveFile implements MutableArchive.
+// Type is removed from type table along with its last file.
+func (archive *Archive) RemoveFile(name game_data.NameHash, _type game_data.TypeHash) error {
+	i := archive.findFile(name, _type)
+	if i < 0 {
+		return game_data.FileNotFoundError(name, _type)
+	}
+	archive.Files = slices.Delete(archive.Files, i, i+1)
+	if t := archive.findType(_type); t >= 0 {
+		if archive.Types[t].Count <= 1 {
+			archive.Types = slices.Delete(archive.Types, t, t+1)
+		} else {
+			archive.Types[t].Count--
+		}
+	}
+	archive.updateCounts()
+	return nil
+}
+
+// RenameFile implements MutableArchive.
+func (archive *Archive) RenameFile(name game_data.NameHash, _type game_data.TypeHash, newName game_data.NameHash) error {
+	i := archive.findFile(name, _type)
+	if i < 0 {
+		return game_data.FileNotFoundError(name, _type)
+	}
+	if archive.findFile(newName, _type) >= 0 {
+		return game_data.FileExistsError(newName, _type)
+	}
+	archive.Files[i].Name = newName
+	return nil
+}
+
+func (archive *Archive) updateCounts() {
+	archive.Header.TypesCount = uint32(len(archive.Types))
+	archive.Header.FilesCount = uint32(len(archive.Files))
+}
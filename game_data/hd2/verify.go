@@ -0,0 +1,116 @@
+package hd2
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/Zekfad/hd-tool/game_data"
+)
+
+const (
+	HeaderSize = 0x48
+	TypeSize   = 0x20
+	FileSize   = 0x50
+)
+
+// TablesSize returns size of header, type and file tables, that is the offset
+// of the first inline buffer.
+func (archive *Archive) TablesSize() uint64 {
+	return HeaderSize +
+		TypeSize*uint64(len(archive.Types)) +
+		FileSize*uint64(len(archive.Files))
+}
+
+// Verify implements VerifiableArchive.
+// Stream and gpu buffer offsets are offsets in .stream and .gpu_resources
+// files.
+func (archive *Archive) Verify() []game_data.Violation {
+	var violations []game_data.Violation
+	report := func(offset uint64, format string, a ...any) {
+		violations = append(violations, game_data.Violation{
+			Offset:  offset,
+			Message: fmt.Sprintf(format, a...),
+		})
+	}
+
+	header := archive.Header
+	if int(header.TypesCount) != len(archive.Types) {
+		report(4, "types count %d doesn't match %d types", header.TypesCount, len(archive.Types))
+	}
+	if int(header.FilesCount) != len(archive.Files) {
+		report(8, "files count %d doesn't match %d files", header.FilesCount, len(archive.Files))
+	}
+
+	counts := map[game_data.TypeHash]uint32{}
+	for _, file := range archive.Files {
+		counts[file.Type]++
+	}
+	seen := map[game_data.TypeHash]bool{}
+	for i, _type := range archive.Types {
+		offset := HeaderSize + TypeSize*uint64(i)
+		if seen[_type.Type] {
+			report(offset, "type %s is listed more than once", _type.Type)
+		}
+		seen[_type.Type] = true
+		if _type.Count != counts[_type.Type] {
+			report(offset, "type %s count %d doesn't match %d files", _type.Type, _type.Count, counts[_type.Type])
+		}
+	}
+
+	type span struct {
+		index  int
+		offset uint64
+		size   uint64
+	}
+	var inline, stream, gpu []span
+	filesOffset := HeaderSize + TypeSize*uint64(len(archive.Types))
+	for i, file := range archive.Files {
+		offset := filesOffset + FileSize*uint64(i)
+		if !seen[file.Type] {
+			report(offset, "file %d type %s is missing from type table", i, file.Type)
+		}
+		if file.Size > 0 {
+			if file.Alignment > 1 && file.Offset%uint64(file.Alignment) != 0 {
+				report(offset, "file %d offset %#X is not aligned to %#X", i, file.Offset, file.Alignment)
+			}
+			inline = append(inline, span{i, file.Offset, uint64(file.Size)})
+		}
+		if file.StreamSize > 0 {
+			stream = append(stream, span{i, file.StreamOffset, uint64(file.StreamSize)})
+		}
+		if file.GpuStreamSize > 0 {
+			if file.GpuAlignment > 1 && file.GpuOffset%uint64(file.GpuAlignment) != 0 {
+				report(offset, "file %d gpu offset %#X is not aligned to %#X", i, file.GpuOffset, file.GpuAlignment)
+			}
+			gpu = append(gpu, span{i, file.GpuOffset, uint64(file.GpuStreamSize)})
+		}
+	}
+
+	checkSpans := func(spans []span, kind string, start uint64, end uint64) {
+		slices.SortStableFunc(spans, func(a, b span) int {
+			return cmp.Compare(a.offset, b.offset)
+		})
+		cursor := start
+		previous := -1
+		for _, s := range spans {
+			switch {
+			case s.offset < start:
+				report(s.offset, "file %d %s buffer overlaps tables ending at %#X", s.index, kind, start)
+			case s.offset < cursor:
+				report(s.offset, "file %d %s buffer overlaps buffer of file %d ending at %#X", s.index, kind, previous, cursor)
+			}
+			if s.offset+s.size > end {
+				report(s.offset, "file %d %s buffer ends at %#X past buffer size %#X", s.index, kind, s.offset+s.size, end)
+			}
+			if s.offset+s.size > cursor {
+				cursor = s.offset + s.size
+				previous = s.index
+			}
+		}
+	}
+	checkSpans(inline, "inline", archive.TablesSize(), header.BufferSize)
+	checkSpans(stream, "stream", 0, ^uint64(0))
+	checkSpans(gpu, "gpu", 0, header.GpuBufferSize)
+	return violations
+}
@@ -20,11 +20,20 @@ type LuaResource struct {
 	Size   uint32
 	Format LuaFormat
 	Data   []byte `bin:"len:Size"`
+
+	// byte order of header fields, little-endian if nil
+	ByteOrder binary.ByteOrder `bin:"-"`
 }
 
 func LuaResourceFromBytes(data []byte) (*LuaResource, error) {
-	reader := binstruct.NewReaderFromBytes(data, binary.LittleEndian, false)
-	var resource LuaResource
+	return LuaResourceFromBytesWithOrder(data, binary.LittleEndian)
+}
+
+// LuaResourceFromBytesWithOrder parses resource of archive with given byte
+// order, see [ByteOrder].
+func LuaResourceFromBytesWithOrder(data []byte, order binary.ByteOrder) (*LuaResource, error) {
+	reader := binstruct.NewReaderFromBytes(data, order, false)
+	resource := LuaResource{ByteOrder: order}
 	err := reader.Unmarshal(&resource)
 	if err != nil {
 		return nil, err
@@ -33,9 +42,13 @@ func LuaResourceFromBytes(data []byte) (*LuaResource, error) {
 }
 
 func (resource LuaResource) ToBytes() ([]byte, error) {
+	order := resource.ByteOrder
+	if order == nil {
+		order = binary.LittleEndian
+	}
 	b := new(bytes.Buffer)
-	binary.Write(b, binary.LittleEndian, uint32(len(resource.Data)))
-	binary.Write(b, binary.LittleEndian, uint32(resource.Format))
+	binary.Write(b, order, uint32(len(resource.Data)))
+	binary.Write(b, order, uint32(resource.Format))
 	b.Write(resource.Data)
 	return b.Bytes(), nil
 }
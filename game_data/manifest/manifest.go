@@ -0,0 +1,497 @@
+package manifest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd1"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
+)
+
+// Manifest describes archive structure that is lost on unpack, so that archive
+// can be rebuilt from unpacked files.
+type Manifest struct {
+	Version game_data.ArchiveVersion
+	HD1     *HD1Archive `json:",omitempty"`
+	HD2     *HD2Archive `json:",omitempty"`
+}
+
+// Buffers references files holding file buffers.
+// Paths are slash separated and relative to unpacked directory, empty path
+// means empty buffer.
+type Buffers struct {
+	Inline string `json:",omitempty"`
+	Stream string `json:",omitempty"`
+	Gpu    string `json:",omitempty"`
+	// Variants reference files holding each variant of HD1 file, Inline is
+	// empty then.
+	Variants []string `json:",omitempty"`
+	// VariantStreams reference files holding stream buffer of each variant of
+	// HD1 file, Stream is empty then. Empty path means empty buffer.
+	VariantStreams []string `json:",omitempty"`
+	// LuaFormat is set if inline file holds Lua resource data without header.
+	LuaFormat *game_data.LuaFormat `json:",omitempty"`
+	// Strings reference strings resource exports of inline buffer or of each
+	// variant, resources are imported from them over inline files, see
+	// [game_data.ImportStrings]. Empty path means buffer is kept.
+	Strings []string `json:",omitempty"`
+}
+
+type HD1Archive struct {
+	BigEndian bool `json:",omitempty"`
+	Magic     []byte
+	Types     []hd1.Type
+	Files     []HD1File
+	// Original identifies packed chunks of archive file manifest was saved
+	// from. Compression isn't reproducible, so chunks of unchanged data are
+	// reused from original archive.
+	Original *HD1Original `json:",omitempty"`
+}
+
+// HD1Original identifies packed header and chunks of original HD1 archive.
+type HD1Original struct {
+	// path of original archive file at unpack time
+	Path         string
+	UnpackedSize uint32
+	Reserved     uint32
+	// CRC-32 of each packed chunk
+	Chunks []uint32
+}
+
+type HD1File struct {
+	Type         game_data.TypeHash
+	Name         game_data.NameHash
+	StreamOffset uint32
+	// Variants are stored joined in inline buffer file, unless they are stored
+	// in separate files.
+	Variants []hd1.VariantHeader
+	Buffers  Buffers
+}
+
+type HD2Archive struct {
+	Header hd2.ArchiveHeader
+	Types  []hd2.Type
+	Files  []HD2File
+}
+
+type HD2File struct {
+	Name            game_data.NameHash
+	Type            game_data.TypeHash
+	Offset          uint64
+	StreamOffset    uint64
+	GpuOffset       uint64
+	BufferOffset    uint64
+	GpuBufferOffset uint64
+	Alignment       uint32
+	GpuAlignment    uint32
+	Index           uint32
+	Buffers         Buffers
+}
+
+// New describes archive, buffers returns references to files holding buffers
+// of file with given index.
+func New(archive game_data.Archive, buffers func(index int) Buffers) (*Manifest, error) {
+	manifest := &Manifest{
+		Version: archive.GetVersion(),
+	}
+	switch archive := archive.(type) {
+	case *hd1.Archive:
+		manifest.HD1 = &HD1Archive{
+			BigEndian: archive.GetByteOrder() == binary.BigEndian,
+			Magic:     archive.Unpacked.Header.Magic,
+			Types:     archive.Unpacked.Types,
+			Files:     make([]HD1File, len(archive.Unpacked.Files)),
+		}
+		for i, file := range archive.Unpacked.Files {
+			manifest.HD1.Files[i] = HD1File{
+				Type:         file.Type,
+				Name:         file.Name,
+				StreamOffset: file.StreamOffset,
+				Variants:     file.VariantHeaders,
+				Buffers:      buffers(i),
+			}
+		}
+	case *hd2.Archive:
+		manifest.HD2 = &HD2Archive{
+			Header: archive.Header,
+			Types:  archive.Types,
+			Files:  make([]HD2File, len(archive.Files)),
+		}
+		for i, file := range archive.Files {
+			manifest.HD2.Files[i] = HD2File{
+				Name:            file.Name,
+				Type:            file.Type,
+				Offset:          file.Offset,
+				StreamOffset:    file.StreamOffset,
+				GpuOffset:       file.GpuOffset,
+				BufferOffset:    file.BufferOffset,
+				GpuBufferOffset: file.GpuBufferOffset,
+				Alignment:       file.Alignment,
+				GpuAlignment:    file.GpuAlignment,
+				Index:           file.Index,
+				Buffers:         buffers(i),
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported archive version %#X", uint32(archive.GetVersion()))
+	}
+	return manifest, nil
+}
+
+func FromFile(name string) (*Manifest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("failed to read file"),
+			err,
+		)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("failed to parse manifest"),
+			err,
+		)
+	}
+	return &manifest, nil
+}
+
+func (manifest Manifest) SaveToFile(name string) error {
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return errors.Join(
+			fmt.Errorf("failed to encode manifest"),
+			err,
+		)
+	}
+	if err := os.WriteFile(name, data, 0666); err != nil {
+		return errors.Join(
+			fmt.Errorf("failed to write manifest"),
+			err,
+		)
+	}
+	return nil
+}
+
+// Archive builds archive described by manifest, buffers are read from files
+// in directory.
+func (manifest Manifest) Archive(directory string) (game_data.Archive, error) {
+	switch {
+	case manifest.HD1 != nil:
+		return manifest.hd1Archive(directory)
+	case manifest.HD2 != nil:
+		return manifest.hd2Archive(directory)
+	default:
+		return nil, fmt.Errorf("manifest of version %#X has no archive description", uint32(manifest.Version))
+	}
+}
+
+func (manifest Manifest) hd1Archive(directory string) (*hd1.Archive, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if manifest.HD1.BigEndian {
+		order = binary.BigEndian
+	}
+	archive := &hd1.Archive{
+		PackedHeader: hd1.PackedHeader{
+			ArchiveVersion: manifest.Version,
+		},
+		ByteOrder: order,
+		Unpacked: hd1.UnpackedArchive{
+			Header: hd1.ArchiveHeader{
+				EntriesCount: uint32(len(manifest.HD1.Files)),
+				Magic:        manifest.HD1.Magic,
+			},
+			Types: manifest.HD1.Types,
+			Files: make([]hd1.File, len(manifest.HD1.Files)),
+		},
+	}
+	if original := manifest.HD1.Original; original != nil && original.Path != "" {
+		chunks, err := original.readChunks(archive.ArchiveVersion, order)
+		if err != nil {
+			return nil, err
+		}
+		archive.UnpackedSize = original.UnpackedSize
+		archive.Reserved = original.Reserved
+		archive.Chunks = chunks
+	}
+	for i, entry := range manifest.HD1.Files {
+		file := hd1.File{
+			FileHeader: hd1.FileHeader{
+				Type:           entry.Type,
+				Name:           entry.Name,
+				VariantsCount:  uint32(len(entry.Variants)),
+				StreamOffset:   entry.StreamOffset,
+				VariantHeaders: entry.Variants,
+			},
+			VariantBuffers: make([][]byte, len(entry.Variants)),
+		}
+		if len(entry.Buffers.Variants) > 0 {
+			if len(entry.Buffers.Variants) != len(entry.Variants) {
+				return nil, fmt.Errorf("file %016X has %d variants, got %d variant files", entry.Name, len(entry.Variants), len(entry.Buffers.Variants))
+			}
+			for j, path := range entry.Buffers.Variants {
+				data, err := readBuffer(directory, path)
+				if err != nil {
+					return nil, err
+				}
+				file.VariantBuffers[j] = data
+			}
+		} else {
+			data, err := entry.Buffers.readInline(directory, order)
+			if err != nil {
+				return nil, err
+			}
+			file.VariantBuffers = splitVariants(data, entry.Variants, func(variant hd1.VariantHeader) uint32 {
+				return variant.Size
+			})
+		}
+
+		if err := entry.Buffers.readStrings(directory, file.VariantBuffers, order); err != nil {
+			return nil, err
+		}
+
+		streams, err := entry.Buffers.readVariantStreams(directory, entry.Variants)
+		if err != nil {
+			return nil, err
+		}
+		file.VariantStreamBuffers = streams
+		archive.Unpacked.Files[i] = file
+	}
+	return archive, nil
+}
+
+// readVariantStreams reads stream buffers of HD1 file variants, nil is returned
+// if file has no stream buffer files.
+func (buffers Buffers) readVariantStreams(directory string, variants []hd1.VariantHeader) ([][]byte, error) {
+	if len(buffers.VariantStreams) > 0 {
+		if len(buffers.VariantStreams) != len(variants) {
+			return nil, fmt.Errorf("file has %d variants, got %d variant stream files", len(variants), len(buffers.VariantStreams))
+		}
+		streams := make([][]byte, len(variants))
+		for j, path := range buffers.VariantStreams {
+			data, err := readBuffer(directory, path)
+			if err != nil {
+				return nil, err
+			}
+			streams[j] = data
+		}
+		return streams, nil
+	}
+	if buffers.Stream == "" {
+		return nil, nil
+	}
+	data, err := readBuffer(directory, buffers.Stream)
+	if err != nil {
+		return nil, err
+	}
+	return splitVariants(data, variants, func(variant hd1.VariantHeader) uint32 {
+		return variant.StreamSize
+	}), nil
+}
+
+// splitVariants splits joined buffer of HD1 file variants by their sizes, all
+// the remaining data goes to the last variant.
+func splitVariants(data []byte, variants []hd1.VariantHeader, size func(hd1.VariantHeader) uint32) [][]byte {
+	buffers := make([][]byte, len(variants))
+	for j, variant := range variants {
+		n := min(int(size(variant)), len(data))
+		if j == len(variants)-1 {
+			n = len(data)
+		}
+		buffers[j] = data[:n]
+		data = data[n:]
+	}
+	return buffers
+}
+
+func (manifest Manifest) hd2Archive(directory string) (*hd2.Archive, error) {
+	archive := &hd2.Archive{
+		Header: manifest.HD2.Header,
+		Types:  manifest.HD2.Types,
+		Files:  make([]hd2.File, len(manifest.HD2.Files)),
+	}
+	archive.Header.ArchiveVersion = manifest.Version
+	for i, entry := range manifest.HD2.Files {
+		inline, err := entry.Buffers.readInline(directory, binary.LittleEndian)
+		if err != nil {
+			return nil, err
+		}
+		inlines := [][]byte{inline}
+		if err := entry.Buffers.readStrings(directory, inlines, binary.LittleEndian); err != nil {
+			return nil, err
+		}
+		inline = inlines[0]
+		stream, err := readBuffer(directory, entry.Buffers.Stream)
+		if err != nil {
+			return nil, err
+		}
+		gpu, err := readBuffer(directory, entry.Buffers.Gpu)
+		if err != nil {
+			return nil, err
+		}
+		archive.Files[i] = hd2.File{
+			Name:            entry.Name,
+			Type:            entry.Type,
+			Offset:          entry.Offset,
+			StreamOffset:    entry.StreamOffset,
+			GpuOffset:       entry.GpuOffset,
+			BufferOffset:    entry.BufferOffset,
+			GpuBufferOffset: entry.GpuBufferOffset,
+			Alignment:       entry.Alignment,
+			GpuAlignment:    entry.GpuAlignment,
+			Index:           entry.Index,
+			InlineBuffer:    inline,
+			StreamBuffer:    stream,
+			GpuBuffer:       gpu,
+		}
+	}
+	return archive, nil
+}
+
+// readInline reads inline buffer, wrapping Lua data into resource of given
+// byte order if needed.
+func (buffers Buffers) readInline(directory string, order binary.ByteOrder) ([]byte, error) {
+	data, err := readBuffer(directory, buffers.Inline)
+	if err != nil {
+		return nil, err
+	}
+	if buffers.LuaFormat == nil {
+		return data, nil
+	}
+	return game_data.LuaResource{
+		Format:    *buffers.LuaFormat,
+		Data:      data,
+		ByteOrder: order,
+	}.ToBytes()
+}
+
+// readStrings replaces inline buffers (joined buffer or variants) with strings
+// resources imported from exports.
+func (buffers Buffers) readStrings(directory string, inline [][]byte, order binary.ByteOrder) error {
+	if len(buffers.Strings) == 0 {
+		return nil
+	}
+	if len(buffers.Strings) != len(inline) {
+		return fmt.Errorf("file has %d inline buffers, got %d strings files", len(inline), len(buffers.Strings))
+	}
+	for j, path := range buffers.Strings {
+		if path == "" {
+			continue
+		}
+		format, ok := game_data.StringsFormatFromPath(path)
+		if !ok {
+			return fmt.Errorf("unknown strings format of %s", path)
+		}
+		name, err := sourcePath(directory, path)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			return errors.Join(
+				fmt.Errorf("failed to read strings %s", path),
+				err,
+			)
+		}
+		data, err := game_data.ImportStrings(file, format, inline[j], order)
+		file.Close()
+		if err != nil {
+			return errors.Join(
+				fmt.Errorf("failed to import strings %s", path),
+				err,
+			)
+		}
+		inline[j] = data
+	}
+	return nil
+}
+
+func readBuffer(directory string, path string) ([]byte, error) {
+	if path == "" {
+		return []byte{}, nil
+	}
+	name, err := sourcePath(directory, path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("failed to read buffer %s", path),
+			err,
+		)
+	}
+	return data, nil
+}
+
+// HD1OriginalFromFile reads packed header and chunk checksums of HD1 archive
+// file, chunks are read one at a time.
+func HD1OriginalFromFile(name string) (*HD1Original, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var chunks []uint32
+	archive, err := hd1.WalkChunks(file, func(index int, chunk hd1.PackedChunk) error {
+		chunks = append(chunks, crc32.ChecksumIEEE(chunk.Data))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &HD1Original{
+		Path:         name,
+		UnpackedSize: archive.UnpackedSize,
+		Reserved:     archive.Reserved,
+		Chunks:       chunks,
+	}, nil
+}
+
+// readChunks reads packed chunks of original archive. Chunks that don't match
+// checksums (e.g. archive was updated since unpack) are left empty, so that
+// their data is compressed again.
+func (original HD1Original) readChunks(version game_data.ArchiveVersion, order binary.ByteOrder) ([]hd1.PackedChunk, error) {
+	file, err := os.Open(original.Path)
+	if err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("failed to open original archive"),
+			err,
+		)
+	}
+	defer file.Close()
+	chunks := make([]hd1.PackedChunk, len(original.Chunks))
+	archive, err := hd1.WalkChunks(file, func(index int, chunk hd1.PackedChunk) error {
+		if index < len(chunks) && crc32.ChecksumIEEE(chunk.Data) == original.Chunks[index] {
+			chunks[index] = chunk
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("failed to read original archive %s", original.Path),
+			err,
+		)
+	}
+	if archive.ArchiveVersion != version || archive.GetByteOrder() != order {
+		return nil, fmt.Errorf("original archive %s doesn't match archive version", original.Path)
+	}
+	return chunks, nil
+}
+
+// sourcePath returns path of file referenced by manifest in source directory.
+// Manifests are untrusted, so paths escaping the directory are rejected, see
+// [game_data.SafePath].
+func sourcePath(directory string, path string) (string, error) {
+	name, ok := game_data.SafePath(path)
+	if !ok {
+		return "", fmt.Errorf("unsafe manifest path %q", path)
+	}
+	return filepath.Join(directory, filepath.FromSlash(name)), nil
+}
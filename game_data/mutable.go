@@ -0,0 +1,61 @@
+package game_data
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrFileNotFound = errors.New("file not found")
+	ErrFileExists   = errors.New("file already exists")
+)
+
+// FileData holds file buffers. For [MutableArchive.ReplaceFile] nil buffer is
+// kept unchanged, for [MutableArchive.AddFile] it is empty.
+type FileData struct {
+	Inline []byte
+	Stream []byte
+	Gpu    []byte
+	// Variants hold inline buffers of individual variants of [VariantFile],
+	// they can't be set along with Inline. Added file has as many variants.
+	Variants [][]byte
+	// VariantStreams hold stream buffers of individual variants, they can't be
+	// set along with Stream.
+	VariantStreams [][]byte
+}
+
+// MutableArchive is an archive which files can be changed in place.
+// Files are identified by name and type, archive tables (type table, counts)
+// are kept consistent with file table. Offsets and sizes are recalculated by
+// writers.
+type MutableArchive interface {
+	Archive
+	ReplaceFile(name NameHash, _type TypeHash, data FileData) error
+	// AddFile appends file to the end of file table.
+	AddFile(name NameHash, _type TypeHash, data FileData) error
+	RemoveFile(name NameHash, _type TypeHash) error
+	RenameFile(name NameHash, _type TypeHash, newName NameHash) error
+}
+
+// Mutate returns mutable archive. Archives that can be copied are copied, so
+// that changes don't affect the original archive.
+func Mutate(archive Archive) (MutableArchive, error) {
+	switch archive := archive.(type) {
+	case interface{ Mutable() MutableArchive }:
+		return archive.Mutable(), nil
+	case MutableArchive:
+		return archive, nil
+	default:
+		return nil, fmt.Errorf("archive %T is not mutable", archive)
+	}
+}
+
+// FileNotFoundError returns error for missing file.
+func FileNotFoundError(name NameHash, _type TypeHash) error {
+	return fmt.Errorf("%w: %016X.%s", ErrFileNotFound, name, _type)
+}
+
+// FileExistsError returns error for already present file.
+func FileExistsError(name NameHash, _type TypeHash) error {
+	return fmt.Errorf("%w: %016X.%s", ErrFileExists, name, _type)
+}
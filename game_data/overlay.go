@@ -0,0 +1,116 @@
+package game_data
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+type overlayKey struct {
+	name  NameHash
+	_type TypeHash
+}
+
+// overlayEntry is location of effective copy of a file.
+type overlayEntry struct {
+	layer int
+	// index in layer file table
+	index int
+	// index in overlay files
+	position int
+}
+
+// Overlay is a view of base archive with patch layers applied on top of it.
+// Each file (name and type) resolves to its copy from the last layer containing
+// it. Files keep base archive order, files added by patches follow them.
+// Layers must not be changed after overlay is created.
+type Overlay struct {
+	layers  []Archive
+	files   []File
+	entries map[overlayKey]overlayEntry
+}
+
+// NewOverlay returns overlay of layers in priority order: base archive first,
+// then its patches.
+func NewOverlay(layers ...Archive) *Overlay {
+	overlay := &Overlay{
+		layers:  layers,
+		entries: map[overlayKey]overlayEntry{},
+	}
+	for i, layer := range layers {
+		for j, file := range layer.GetFiles() {
+			key := overlayKey{file.GetName(), file.GetType()}
+			entry, ok := overlay.entries[key]
+			if ok {
+				overlay.files[entry.position] = file
+			} else {
+				entry.position = len(overlay.files)
+				overlay.files = append(overlay.files, file)
+			}
+			entry.layer, entry.index = i, j
+			overlay.entries[key] = entry
+		}
+	}
+	return overlay
+}
+
+// Layers returns overlay layers in priority order.
+func (overlay *Overlay) Layers() []Archive {
+	return overlay.layers
+}
+
+// Resolve returns effective copy of file, index of layer it comes from and its
+// index in file table of that layer.
+func (overlay *Overlay) Resolve(name NameHash, _type TypeHash) (file File, layer int, index int, ok bool) {
+	entry, ok := overlay.entries[overlayKey{name, _type}]
+	if !ok {
+		return nil, 0, 0, false
+	}
+	return overlay.files[entry.position], entry.layer, entry.index, true
+}
+
+// GetVersion implements Archive.
+func (overlay *Overlay) GetVersion() ArchiveVersion {
+	return overlay.layers[0].GetVersion()
+}
+
+// GetChecksum implements Archive.
+func (overlay *Overlay) GetChecksum() uint32 {
+	return overlay.layers[0].GetChecksum()
+}
+
+// GetByteOrder implements ByteOrderArchive.
+func (overlay *Overlay) GetByteOrder() binary.ByteOrder {
+	return ByteOrder(overlay.layers[0])
+}
+
+// GetTypes implements Archive. Each type comes from the last layer having it,
+// counts of [CountedType] are recalculated from overlay files.
+func (overlay *Overlay) GetTypes() []Type {
+	var types []Type
+	seen := map[TypeHash]int{}
+	for _, layer := range overlay.layers {
+		for _, _type := range layer.GetTypes() {
+			if i, ok := seen[_type.GetType()]; ok {
+				types[i] = _type
+				continue
+			}
+			seen[_type.GetType()] = len(types)
+			types = append(types, _type)
+		}
+	}
+	counts := map[TypeHash]uint32{}
+	for _, file := range overlay.files {
+		counts[file.GetType()]++
+	}
+	for i, _type := range types {
+		if counted, ok := _type.(CountedType); ok {
+			types[i] = counted.WithCount(counts[_type.GetType()])
+		}
+	}
+	return types
+}
+
+// GetFiles implements Archive.
+func (overlay *Overlay) GetFiles() []File {
+	return slices.Clone(overlay.files)
+}
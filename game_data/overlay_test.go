@@ -0,0 +1,44 @@
+package game_data_test
+
+import (
+	"testing"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
+)
+
+// TestOverlayTypeCounts checks that type counts are taken from merged files
+// rather than from the layer type comes from.
+func TestOverlayTypeCounts(t *testing.T) {
+	const (
+		typeA game_data.TypeHash = 1
+		typeB game_data.TypeHash = 2
+	)
+	base, patch := hd2.NewArchive(), hd2.NewArchive()
+	for _, file := range []struct {
+		archive *hd2.Archive
+		name    game_data.NameHash
+		_type   game_data.TypeHash
+	}{
+		{base, 1, typeA},
+		{base, 2, typeA},
+		{base, 3, typeB},
+		{patch, 2, typeA},
+		{patch, 4, typeA},
+	} {
+		if err := file.archive.AddFile(file.name, file._type, game_data.FileData{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[game_data.TypeHash]uint32{typeA: 3, typeB: 1}
+	types := game_data.NewOverlay(base, patch).GetTypes()
+	if len(types) != len(want) {
+		t.Fatalf("got %d types, want %d", len(types), len(want))
+	}
+	for _, _type := range types {
+		if got := _type.(hd2.Type).Count; got != want[_type.GetType()] {
+			t.Errorf("type %s: got count %d, want %d", _type.GetType(), got, want[_type.GetType()])
+		}
+	}
+}
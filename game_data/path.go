@@ -0,0 +1,27 @@
+package game_data
+
+import (
+	"path"
+	"strings"
+)
+
+// SafePath normalizes separators of relative path and cleans it. Absolute
+// paths, paths with volume names and paths escaping base directory with ".."
+// are rejected. Paths from untrusted sources (Hash DB names, manifests) must
+// pass it before they are joined with a directory.
+func SafePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if name == "" || strings.HasPrefix(name, "/") || strings.ContainsAny(name, ":\x00") {
+		return "", false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+	name = path.Clean(name)
+	if name == "." {
+		return "", false
+	}
+	return name, true
+}
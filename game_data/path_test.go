@@ -0,0 +1,35 @@
+package game_data
+
+import "testing"
+
+func TestSafePath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"content/file.lua", "content/file.lua", true},
+		{`content\textures\file.texture`, "content/textures/file.texture", true},
+		{"content//./file.lua", "content/file.lua", true},
+		{"content/", "content", true},
+		{"..file", "..file", true},
+		{"", "", false},
+		{".", "", false},
+		{"./", "", false},
+		{"/etc/passwd", "", false},
+		{`\windows\file`, "", false},
+		{"C:/windows/file", "", false},
+		{"C:file", "", false},
+		{"../file", "", false},
+		{`content\..\..\file`, "", false},
+		{"content/../file", "", false},
+		{"content/..", "", false},
+		{"file\x00.lua", "", false},
+	}
+	for _, test := range tests {
+		got, ok := SafePath(test.name)
+		if got != test.want || ok != test.ok {
+			t.Errorf("SafePath(%q) = %q, %v, want %q, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
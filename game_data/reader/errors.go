@@ -0,0 +1,85 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/bits"
+	"strings"
+
+	"github.com/Zekfad/hd-tool/game_data"
+)
+
+var (
+	// ErrUnknownVersion is matched by [UnknownVersionError], file is likely not
+	// an archive at all.
+	ErrUnknownVersion = errors.New("unknown archive version")
+	// ErrTruncated means archive data ends before its tables or buffers do.
+	ErrTruncated = errors.New("archive is truncated")
+	// ErrCorrupt means archive is of known version, but can't be decoded.
+	ErrCorrupt = errors.New("archive is corrupt")
+	// ErrIO means archive or directory can't be read.
+	ErrIO = errors.New("I/O error")
+)
+
+// UnknownVersionError is returned for files without registered decoder.
+// Stingray bundles of versions other than [Versions] (e.g. 0xF0000006 or
+// Darktide bundles) are reported as unsupported, not as corrupt.
+type UnknownVersionError struct {
+	Version game_data.ArchiveVersion
+	// Short is set if file is shorter than version word.
+	Short bool
+}
+
+// stingrayVersionMask matches version words of Stingray (Bitsquid) bundles.
+const stingrayVersionMask = 0xffffff00
+
+func (err *UnknownVersionError) Error() string {
+	version := uint32(err.Version)
+	swapped := bits.ReverseBytes32(version)
+	var supported []string
+	for _, version := range Versions() {
+		if Experimental(version) {
+			supported = append(supported, fmt.Sprintf("%#08X (experimental)", uint32(version)))
+			continue
+		}
+		supported = append(supported, fmt.Sprintf("%#08X", uint32(version)))
+	}
+
+	switch {
+	case err.Short:
+		return "not a Stingray bundle, file is shorter than version word"
+	case version&stingrayVersionMask == 0xf0000000:
+		return fmt.Sprintf("unsupported Stingray bundle version %#08X (supported: %s)",
+			version, strings.Join(supported, ", "))
+	case swapped&stingrayVersionMask == 0xf0000000:
+		return fmt.Sprintf("unsupported big-endian Stingray bundle version %#08X (supported: %s)",
+			swapped, strings.Join(supported, ", "))
+	default:
+		return fmt.Sprintf("not a Stingray bundle, unexpected version word %#08X", version)
+	}
+}
+
+func (err *UnknownVersionError) Is(target error) bool {
+	return target == ErrUnknownVersion
+}
+
+// classifyError wraps decoding error with one of ErrIO, ErrTruncated or
+// ErrCorrupt.
+func classifyError(err error) error {
+	var pathError *fs.PathError
+	switch {
+	case errors.Is(err, ErrUnknownVersion),
+		errors.Is(err, ErrIO),
+		errors.Is(err, ErrTruncated),
+		errors.Is(err, ErrCorrupt):
+		return err
+	case errors.As(err, &pathError):
+		return errors.Join(ErrIO, err)
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.Join(ErrTruncated, err)
+	default:
+		return errors.Join(ErrCorrupt, err)
+	}
+}
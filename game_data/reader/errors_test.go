@@ -0,0 +1,37 @@
+package reader
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestArchiveFromBytesUnknownVersion(t *testing.T) {
+	bigEndian := make([]byte, 16)
+	binary.BigEndian.PutUint32(bigEndian, 0xf0000006)
+	littleEndian := make([]byte, 16)
+	binary.LittleEndian.PutUint32(littleEndian, 0xf0000006)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		message string
+	}{
+		{"short", []byte{0xf0}, "not a Stingray bundle, file is shorter than version word"},
+		{"other file", []byte("PK\x03\x04"), "not a Stingray bundle, unexpected version word 0X04034B50"},
+		{"Stingray", littleEndian, "unsupported Stingray bundle version 0XF0000006"},
+		{"big-endian Stingray", bigEndian, "unsupported big-endian Stingray bundle version 0XF0000006"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ArchiveFromBytes(test.data)
+			if !errors.Is(err, ErrUnknownVersion) {
+				t.Fatalf("got error %v, want %v", err, ErrUnknownVersion)
+			}
+			if !strings.HasPrefix(err.Error(), test.message) {
+				t.Fatalf("got error %q, want %q", err, test.message)
+			}
+		})
+	}
+}
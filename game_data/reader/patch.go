@@ -0,0 +1,139 @@
+package reader
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Zekfad/hd-tool/game_data"
+)
+
+// PatchExtensionPrefix starts extension of patch layer of base archive:
+// <base>.patch_0, <base>.patch_1 and so on, higher number has higher priority.
+const PatchExtensionPrefix = ".patch_"
+
+// PatchMode tells how patch layers are treated while scanning directory.
+type PatchMode int
+
+const (
+	// Patch layers are applied on top of their base archive, patches without
+	// base are scanned on their own.
+	PatchesOverlay PatchMode = iota
+	// Patch layers are scanned as separate archives.
+	PatchesSeparate
+	// Patch layers are skipped.
+	PatchesIgnore
+)
+
+// ParsePatchPath splits path of patch layer into base archive path and patch
+// number.
+func ParsePatchPath(path string) (string, int, bool) {
+	extension := filepath.Ext(path)
+	number, ok := strings.CutPrefix(extension, PatchExtensionPrefix)
+	if !ok {
+		return "", 0, false
+	}
+	index, err := strconv.Atoi(number)
+	if err != nil || index < 0 {
+		return "", 0, false
+	}
+	return strings.TrimSuffix(path, extension), index, true
+}
+
+// PatchPaths returns paths of existing patch layers of base archive in
+// priority order.
+func PatchPaths(name string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(name))
+	if err != nil {
+		return nil, errors.Join(ErrIO, err)
+	}
+
+	type patch struct {
+		path  string
+		index int
+	}
+	var patches []patch
+	base := filepath.Base(name)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if patchBase, index, ok := ParsePatchPath(entry.Name()); ok && patchBase == base {
+			patches = append(patches, patch{filepath.Join(filepath.Dir(name), entry.Name()), index})
+		}
+	}
+	slices.SortFunc(patches, func(a, b patch) int {
+		return cmp.Compare(a.index, b.index)
+	})
+
+	paths := make([]string, len(patches))
+	for i, patch := range patches {
+		paths[i] = patch.path
+	}
+	return paths, nil
+}
+
+// LayeredArchiveFromFile reads archive along with its patch layers into
+// memory. Archive without patches is returned as is, otherwise
+// [game_data.Overlay] is returned.
+func LayeredArchiveFromFile(name string) (game_data.Archive, error) {
+	base, err := ArchiveFromFile(name)
+	if err != nil {
+		return nil, err
+	}
+	patches, err := PatchPaths(name)
+	if err != nil || len(patches) == 0 {
+		return base, err
+	}
+
+	layers := []game_data.Archive{base}
+	for _, patch := range patches {
+		layer, err := ArchiveFromFile(patch)
+		if err != nil {
+			return nil, errors.Join(
+				fmt.Errorf("failed to read patch %s", filepath.Base(patch)),
+				err,
+			)
+		}
+		layers = append(layers, layer)
+	}
+	return game_data.NewOverlay(layers...), nil
+}
+
+// OpenLayeredArchive opens archive along with its patch layers for lazy
+// reading, see [LayeredArchiveFromFile].
+func OpenLayeredArchive(name string) (*ArchiveFile, error) {
+	archiveFile, err := OpenArchive(name)
+	if err != nil {
+		return nil, err
+	}
+	patches, err := PatchPaths(name)
+	if err != nil || len(patches) == 0 {
+		if err != nil {
+			archiveFile.Close()
+			return nil, err
+		}
+		return archiveFile, nil
+	}
+
+	layers := []game_data.Archive{archiveFile.Archive}
+	for _, patch := range patches {
+		layer, err := OpenArchive(patch)
+		if err != nil {
+			archiveFile.Close()
+			return nil, errors.Join(
+				fmt.Errorf("failed to open patch %s", filepath.Base(patch)),
+				err,
+			)
+		}
+		layers = append(layers, layer.Archive)
+		archiveFile.files = append(archiveFile.files, layer.files...)
+	}
+	archiveFile.Archive = game_data.NewOverlay(layers...)
+	return archiveFile, nil
+}
@@ -1,45 +1,51 @@
 package reader
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"iter"
 	"os"
 	"path/filepath"
 
 	"github.com/Zekfad/hd-tool/game_data"
-	"github.com/Zekfad/hd-tool/game_data/hd1"
-	"github.com/Zekfad/hd-tool/game_data/hd2"
-	"github.com/ghostiam/binstruct"
+	"github.com/Zekfad/hd-tool/glob"
+	"github.com/Zekfad/hd-tool/pool"
 )
 
 func ArchiveFromBytes(data []byte) (game_data.Archive, error) {
-	reader := binstruct.NewReaderFromBytes(data, binary.LittleEndian, false)
-	version, err := reader.ReadInt32()
-	if err != nil {
-		return nil, err
-	}
-	reader.Seek(0, io.SeekStart)
-	switch game_data.ArchiveVersion(version) {
-	case game_data.ArchiveVersionHD2:
-		var archive hd2.Archive
-		err = reader.Unmarshal(&archive)
-		if err != nil {
-			return nil, err
-		}
-		return archive, nil
-	case game_data.ArchiveVersionHD1:
-		var archive hd1.Archive
-		err = reader.Unmarshal(&archive)
-		if err != nil {
-			return nil, err
+	return ArchiveFromReaderAt(bytes.NewReader(data), int64(len(data)))
+}
+
+// ArchiveFromReaderAt parses archive of size bytes from r.
+// Formats that support lazy reading (HD2) only parse tables and read file
+// buffers from r on demand, therefore r must be readable while archive is used.
+//
+// Returned error matches one of ErrUnknownVersion, ErrTruncated, ErrCorrupt or
+// ErrIO.
+func ArchiveFromReaderAt(r io.ReaderAt, size int64) (game_data.Archive, error) {
+	var header [4]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		// file can't be an archive if it doesn't fit version word
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, &UnknownVersionError{Short: true}
 		}
-		return archive, nil
-	default:
-		return nil, fmt.Errorf("failed to decode archive of unknown version %#X", version)
+		return nil, classifyError(err)
+	}
+	// decoder detects byte order of big-endian archive itself
+	decoder, _, _, ok := lookupVersion(header, Lookup)
+	if !ok {
+		version := game_data.ArchiveVersion(binary.LittleEndian.Uint32(header[:]))
+		return nil, &UnknownVersionError{Version: version}
 	}
+	archive, err := decoder(r, size)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	return archive, nil
 }
 
 func ArchiveFromFile(name string) (game_data.Archive, error) {
@@ -47,6 +53,7 @@ func ArchiveFromFile(name string) (game_data.Archive, error) {
 	if err != nil {
 		return nil, errors.Join(
 			fmt.Errorf("failed to open file"),
+			ErrIO,
 			err,
 		)
 	}
@@ -56,6 +63,7 @@ func ArchiveFromFile(name string) (game_data.Archive, error) {
 	if err != nil {
 		return nil, errors.Join(
 			fmt.Errorf("failed to read file"),
+			ErrIO,
 			err,
 		)
 	}
@@ -67,34 +75,253 @@ func ArchiveFromFile(name string) (game_data.Archive, error) {
 			err,
 		)
 	}
+
+	if archive, ok := archive.(game_data.CompanionArchive); ok {
+		for _, extension := range archive.GetCompanionExtensions() {
+			if err := loadCompanionFile(name+extension, extension, archive); err != nil {
+				return nil, errors.Join(
+					fmt.Errorf("failed to load companion file %s", extension),
+					ErrIO,
+					err,
+				)
+			}
+		}
+	}
 	return archive, nil
 }
 
-func ArchivesFromDirectory(dirname string) iter.Seq2[string, game_data.Archive] {
-	return func(yield func(path string, archive game_data.Archive) bool) {
-		entries, err := os.ReadDir(dirname)
+// loadCompanionFile reads file into memory and sets it as archive companion
+// source, missing file is not an error.
+func loadCompanionFile(name string, extension string, archive game_data.CompanionArchive) error {
+	buffer, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	archive.SetCompanionSource(extension, bytes.NewReader(buffer))
+	return nil
+}
+
+// ArchiveFile is an archive opened for lazy reading.
+// Underlying files are kept open until Close is called.
+type ArchiveFile struct {
+	game_data.Archive
+
+	files []*os.File
+}
+
+// OpenArchive opens archive for lazy reading: only tables are parsed and file
+// buffers are read on demand.
+func OpenArchive(name string) (*ArchiveFile, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("failed to open file"),
+			ErrIO,
+			err,
+		)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, errors.Join(
+			fmt.Errorf("failed to stat file"),
+			ErrIO,
+			err,
+		)
+	}
+
+	archive, err := ArchiveFromReaderAt(file, stat.Size())
+	if err != nil {
+		file.Close()
+		return nil, errors.Join(
+			fmt.Errorf("failed to parse file"),
+			err,
+		)
+	}
+	archiveFile := &ArchiveFile{
+		Archive: archive,
+		files:   []*os.File{file},
+	}
+
+	if archive, ok := archive.(game_data.CompanionArchive); ok {
+		for _, extension := range archive.GetCompanionExtensions() {
+			if err := archiveFile.openCompanionFile(name+extension, extension, archive); err != nil {
+				archiveFile.Close()
+				return nil, errors.Join(
+					fmt.Errorf("failed to open companion file %s", extension),
+					ErrIO,
+					err,
+				)
+			}
+		}
+	}
+	return archiveFile, nil
+}
+
+// openCompanionFile opens file and sets it as archive companion source, missing
+// file is not an error.
+func (archiveFile *ArchiveFile) openCompanionFile(name string, extension string, archive game_data.CompanionArchive) error {
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	archiveFile.files = append(archiveFile.files, file)
+	archive.SetCompanionSource(extension, file)
+	return nil
+}
+
+// Close closes underlying files, archive buffers can't be read afterwards.
+func (archive *ArchiveFile) Close() error {
+	var errs []error
+	for _, file := range archive.files {
+		errs = append(errs, file.Close())
+	}
+	archive.files = nil
+	return errors.Join(errs...)
+}
+
+// ScanOptions configures archive candidates lookup in directory.
+type ScanOptions struct {
+	// Descend into subdirectories.
+	Recursive bool
+	// Glob patterns (see [glob.Match]) of slash separated paths relative to
+	// scanned directory. Candidate must match any of Include patterns if there
+	// are some, and none of Exclude patterns.
+	Include []string
+	Exclude []string
+	// Number of archives parsed in parallel, non-positive means number of CPUs.
+	Jobs int
+	// How patch layers (<base>.patch_N files) are scanned.
+	Patches PatchMode
+}
+
+// ScanResult is a result of opening archive candidate.
+type ScanResult struct {
+	Path string
+	// Archive is nil if Err is set.
+	Archive game_data.Archive
+	// Err matches one of ErrUnknownVersion, ErrTruncated, ErrCorrupt or ErrIO.
+	Err error
+}
+
+// ArchivePaths returns paths of archive candidates in directory: files
+// without extension and patch layers according to options.Patches, filtered by
+// options. Patch layers are matched by path of their base archive.
+// Directories that can't be read are reported in error, but don't stop lookup.
+func ArchivePaths(dirname string, options ScanOptions) ([]string, error) {
+	var paths []string
+	var errs []error
+	err := filepath.WalkDir(dirname, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
-			fmt.Printf("Failed to read directory: %s", err)
-			return
+			errs = append(errs, errors.Join(
+				fmt.Errorf("failed to read directory %s", path),
+				ErrIO,
+				err,
+			))
+			return nil
+		}
+		if entry.IsDir() {
+			if path != dirname && !options.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		candidate := path
+		if filepath.Ext(entry.Name()) != "" {
+			base, _, ok := ParsePatchPath(path)
+			if !ok || !isPatchCandidate(base, options.Patches) {
+				return nil
+			}
+			candidate = base
 		}
 
-		for _, file := range entries {
-			if file.IsDir() {
-				continue
+		relative, err := filepath.Rel(dirname, candidate)
+		if err != nil {
+			return err
+		}
+		relative = filepath.ToSlash(relative)
+		if len(options.Include) > 0 {
+			if matched, err := glob.MatchAny(options.Include, relative); err != nil || !matched {
+				return err
 			}
-			if filepath.Ext(file.Name()) == "" {
-				fullPath := filepath.Join(dirname, file.Name())
-
-				// fmt.Printf("Candidate %s ... ", fullPath)
-				archive, err := ArchiveFromFile(fullPath)
-				if err != nil {
-					// fmt.Printf("not a package: %s\n", err)
-					continue
-				}
-				// fmt.Printf("is a valid package!\n")
-				if !yield(fullPath, archive) {
+		}
+		if matched, err := glob.MatchAny(options.Exclude, relative); err != nil || matched {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return paths, errors.Join(errs...)
+}
+
+func isPatchCandidate(base string, mode PatchMode) bool {
+	switch mode {
+	case PatchesSeparate:
+		return true
+	case PatchesOverlay:
+		// patch without base archive
+		_, err := os.Stat(base)
+		return errors.Is(err, os.ErrNotExist)
+	default:
+		return false
+	}
+}
+
+// ArchivesFromDirectory opens archive candidates in directory for lazy
+// reading and yields result for each of them, including ones that failed to
+// open. With [PatchesOverlay] base archives are yielded as
+// [game_data.Overlay] if they have patch layers. Failure to list directory is
+// yielded as a result with directory path.
+// Archives are parsed by up to options.Jobs goroutines, but yielded in
+// directory order.
+// Each archive is closed once the loop advances, so it must not be retained.
+func ArchivesFromDirectory(dirname string, options ScanOptions) iter.Seq[ScanResult] {
+	return func(yield func(result ScanResult) bool) {
+		paths, err := ArchivePaths(dirname, options)
+		if err != nil {
+			if !yield(ScanResult{Path: dirname, Err: err}) {
+				return
+			}
+		}
+
+		type opened struct {
+			archive *ArchiveFile
+			err     error
+		}
+		open := func(path string) opened {
+			if options.Patches == PatchesOverlay {
+				archive, err := OpenLayeredArchive(path)
+				return opened{archive, err}
+			}
+			archive, err := OpenArchive(path)
+			return opened{archive, err}
+		}
+		release := func(result opened) {
+			if result.archive != nil {
+				result.archive.Close()
+			}
+		}
+
+		for path, result := range pool.Map(paths, options.Jobs, open, release) {
+			if result.err != nil {
+				if !yield(ScanResult{Path: path, Err: result.err}) {
 					return
 				}
+				continue
+			}
+			next := yield(ScanResult{Path: path, Archive: result.archive.Archive})
+			result.archive.Close()
+			if !next {
+				return
 			}
 		}
 	}
@@ -0,0 +1,93 @@
+package reader
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd1"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
+)
+
+// Decoder parses archive of size bytes from r.
+type Decoder func(r io.ReaderAt, size int64) (game_data.Archive, error)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[game_data.ArchiveVersion]Decoder{}
+	// versions which layout isn't verified against game files
+	experimental = map[game_data.ArchiveVersion]bool{
+		game_data.ArchiveVersionVT2: true,
+	}
+)
+
+// built-in formats are always available, so that library users don't need to
+// import format packages for side effects
+func init() {
+	for _, version := range hd1.Versions {
+		Register(version, decodeHD1)
+		RegisterWalker(version, walkHD1)
+	}
+	Register(game_data.ArchiveVersionHD2, decodeHD2)
+}
+
+func decodeHD1(r io.ReaderAt, size int64) (game_data.Archive, error) {
+	archive, err := hd1.ArchiveFromReaderAt(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func decodeHD2(r io.ReaderAt, size int64) (game_data.Archive, error) {
+	archive, err := hd2.ArchiveFromReaderAt(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// Register makes decoder available for archives of given version.
+// HD1 and HD2 formats are registered by this package, other formats register
+// themselves on import. Registering the same version twice panics.
+func Register(version game_data.ArchiveVersion, decoder Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	if decoder == nil {
+		panic("reader: Register decoder is nil")
+	}
+	if _, dup := decoders[version]; dup {
+		panic(fmt.Sprintf("reader: Register called twice for version %#08X", uint32(version)))
+	}
+	decoders[version] = decoder
+}
+
+// Lookup returns decoder registered for given version.
+func Lookup(version game_data.ArchiveVersion) (Decoder, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	decoder, ok := decoders[version]
+	return decoder, ok
+}
+
+// Experimental reports whether decoder of version is experimental: archive
+// layout isn't verified against game files and archives can't be written.
+func Experimental(version game_data.ArchiveVersion) bool {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	return experimental[version]
+}
+
+// Versions returns sorted list of registered versions.
+func Versions() []game_data.ArchiveVersion {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	versions := make([]game_data.ArchiveVersion, 0, len(decoders))
+	for version := range decoders {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions
+}
@@ -0,0 +1,130 @@
+package reader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd1"
+)
+
+// WalkFunc is called for each file of walked archive with its index in file
+// table and reader of its inline buffer, which is valid until it returns.
+type WalkFunc func(index int, file game_data.File, inline io.Reader) error
+
+// Walker reads archive sequentially from r and calls fn for each file in file
+// table order. companion returns reader of companion file with given extension,
+// or nil if there's no such file. Returned archive has tables only.
+type Walker func(r io.Reader, companion func(extension string) (io.ReaderAt, error), fn WalkFunc) (game_data.Archive, error)
+
+var (
+	walkersMu sync.RWMutex
+	walkers   = map[game_data.ArchiveVersion]Walker{}
+)
+
+func walkHD1(r io.Reader, companion func(extension string) (io.ReaderAt, error), fn WalkFunc) (game_data.Archive, error) {
+	stream, err := companion(hd1.StreamExtension)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := hd1.WalkArchive(r, stream, func(index int, file hd1.File, data io.Reader) error {
+		return fn(index, file, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// RegisterWalker makes sequential walker available for archives of given
+// version. Formats without walker are read with their decoder. Registering the
+// same version twice panics.
+func RegisterWalker(version game_data.ArchiveVersion, walker Walker) {
+	walkersMu.Lock()
+	defer walkersMu.Unlock()
+	if walker == nil {
+		panic("reader: RegisterWalker walker is nil")
+	}
+	if _, dup := walkers[version]; dup {
+		panic(fmt.Sprintf("reader: RegisterWalker called twice for version %#08X", uint32(version)))
+	}
+	walkers[version] = walker
+}
+
+// LookupWalker returns walker registered for given version.
+func LookupWalker(version game_data.ArchiveVersion) (Walker, bool) {
+	walkersMu.RLock()
+	defer walkersMu.RUnlock()
+	walker, ok := walkers[version]
+	return walker, ok
+}
+
+// WalkArchiveFile reads archive file sequentially with bounded memory, see
+// [Walker]. ok is false if version of archive has no registered walker, then
+// nothing is walked and archive should be opened with [OpenArchive].
+// opened is called before fn with archive version and byte order.
+// Companion files are opened next to archive file, missing ones are skipped.
+func WalkArchiveFile(
+	name string,
+	opened func(version game_data.ArchiveVersion, order binary.ByteOrder),
+	fn WalkFunc,
+) (archive game_data.Archive, ok bool, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, false, errors.Join(ErrIO, err)
+	}
+	defer file.Close()
+
+	buffered := bufio.NewReader(file)
+	header, err := buffered.Peek(4)
+	if err != nil {
+		// not an archive, reported by decoder
+		return nil, false, nil
+	}
+	walker, version, order, ok := lookupVersion([4]byte(header), LookupWalker)
+	if !ok {
+		return nil, false, nil
+	}
+
+	var companions []*os.File
+	defer func() {
+		for _, companion := range companions {
+			companion.Close()
+		}
+	}()
+	companion := func(extension string) (io.ReaderAt, error) {
+		companion, err := os.Open(name + extension)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.Join(ErrIO, err)
+		}
+		companions = append(companions, companion)
+		return companion, nil
+	}
+
+	opened(version, order)
+	archive, err = walker(buffered, companion, fn)
+	if err != nil {
+		return nil, true, classifyError(err)
+	}
+	return archive, true, nil
+}
+
+// lookupVersion looks up entry of archive version stored in header, version
+// is read as big-endian if there's no entry for little-endian one.
+func lookupVersion[T any](header [4]byte, lookup func(game_data.ArchiveVersion) (T, bool)) (T, game_data.ArchiveVersion, binary.ByteOrder, bool) {
+	version := game_data.ArchiveVersion(binary.LittleEndian.Uint32(header[:]))
+	if entry, ok := lookup(version); ok {
+		return entry, version, binary.LittleEndian, true
+	}
+	version = game_data.ArchiveVersion(binary.BigEndian.Uint32(header[:]))
+	entry, ok := lookup(version)
+	return entry, version, binary.BigEndian, ok
+}
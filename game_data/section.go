@@ -0,0 +1,30 @@
+package game_data
+
+import (
+	"errors"
+	"io"
+)
+
+// SectionReader returns reader of size bytes of r starting at offset. Unlike
+// [io.SectionReader] it reports [io.ErrUnexpectedEOF] if r ends before section
+// does.
+func SectionReader(r io.ReaderAt, offset int64, size int64) io.Reader {
+	return &sectionReader{
+		r:         io.NewSectionReader(r, offset, size),
+		remaining: size,
+	}
+}
+
+type sectionReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (r *sectionReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	if errors.Is(err, io.EOF) && r.remaining > 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
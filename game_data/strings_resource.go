@@ -0,0 +1,295 @@
+package game_data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const stringsHeaderSize = 16
+
+// StringsResource is a compiled strings resource: localized strings addressed
+// by 32-bit hash of string ID.
+//
+// Resource starts with header of Magic, Version, strings count and Language
+// (all uint32), followed by table of string IDs and table of string offsets
+// (uint32 each). Strings are null-terminated UTF-8, offsets are relative to
+// resource start.
+type StringsResource struct {
+	Magic    uint32
+	Version  uint32
+	Language uint32
+	Strings  []StringsEntry
+
+	// byte order of header fields and tables, little-endian if nil
+	ByteOrder binary.ByteOrder
+}
+
+type StringsEntry struct {
+	ID    uint32
+	Value string
+}
+
+func StringsResourceFromBytes(data []byte) (*StringsResource, error) {
+	return StringsResourceFromBytesWithOrder(data, binary.LittleEndian)
+}
+
+// StringsResourceFromBytesWithOrder parses resource of archive with given byte
+// order, see [ByteOrder].
+func StringsResourceFromBytesWithOrder(data []byte, order binary.ByteOrder) (*StringsResource, error) {
+	if len(data) < stringsHeaderSize {
+		return nil, fmt.Errorf("strings header is truncated: %w", io.ErrUnexpectedEOF)
+	}
+	resource := StringsResource{
+		Magic:     order.Uint32(data[0:]),
+		Version:   order.Uint32(data[4:]),
+		Language:  order.Uint32(data[12:]),
+		ByteOrder: order,
+	}
+	count := uint64(order.Uint32(data[8:]))
+	if stringsHeaderSize+count*8 > uint64(len(data)) {
+		return nil, fmt.Errorf("strings tables of %d entries are truncated: %w", count, io.ErrUnexpectedEOF)
+	}
+	ids := data[stringsHeaderSize:]
+	offsets := ids[count*4:]
+	resource.Strings = make([]StringsEntry, count)
+	for i := range resource.Strings {
+		id := order.Uint32(ids[i*4:])
+		offset := order.Uint32(offsets[i*4:])
+		if uint64(offset) > uint64(len(data)) {
+			return nil, fmt.Errorf("string %08X offset %#X is out of bounds", id, offset)
+		}
+		size := bytes.IndexByte(data[offset:], 0)
+		if size < 0 {
+			return nil, fmt.Errorf("string %08X is not null-terminated", id)
+		}
+		value := data[offset : int(offset)+size]
+		if !utf8.Valid(value) {
+			return nil, fmt.Errorf("string %08X is not valid UTF-8", id)
+		}
+		resource.Strings[i] = StringsEntry{
+			ID:    id,
+			Value: string(value),
+		}
+	}
+	return &resource, nil
+}
+
+// ToBytes encodes resource, strings are stored in table order.
+func (resource StringsResource) ToBytes() ([]byte, error) {
+	order := resource.ByteOrder
+	if order == nil {
+		order = binary.LittleEndian
+	}
+	count := len(resource.Strings)
+	b := new(bytes.Buffer)
+	binary.Write(b, order, resource.Magic)
+	binary.Write(b, order, resource.Version)
+	binary.Write(b, order, uint32(count))
+	binary.Write(b, order, resource.Language)
+	for _, entry := range resource.Strings {
+		binary.Write(b, order, entry.ID)
+	}
+	offset := stringsHeaderSize + count*8
+	for _, entry := range resource.Strings {
+		if bytes.IndexByte([]byte(entry.Value), 0) >= 0 {
+			return nil, fmt.Errorf("string %08X contains null character", entry.ID)
+		}
+		binary.Write(b, order, uint32(offset))
+		offset += len(entry.Value) + 1
+	}
+	for _, entry := range resource.Strings {
+		b.WriteString(entry.Value)
+		b.WriteByte(0)
+	}
+	return b.Bytes(), nil
+}
+
+/**
+ * Export
+ */
+
+// StringsFormat is file format of exported strings resource, it's also export
+// file extension.
+type StringsFormat string
+
+const (
+	// JSON document of header fields and strings.
+	StringsFormatJSON StringsFormat = "json"
+	// CSV table of ID and Value columns, header fields are not exported.
+	StringsFormatCSV StringsFormat = "csv"
+)
+
+func ParseStringsFormat(value string) (StringsFormat, error) {
+	switch format := StringsFormat(strings.ToLower(value)); format {
+	case StringsFormatJSON, StringsFormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown strings format %q, expected json or csv", value)
+	}
+}
+
+// StringsFormatFromPath returns format of export file by its extension.
+func StringsFormatFromPath(path string) (StringsFormat, bool) {
+	dot := strings.LastIndexByte(path, '.')
+	if dot < 0 {
+		return "", false
+	}
+	format, err := ParseStringsFormat(path[dot+1:])
+	return format, err == nil
+}
+
+// Export writes resource in given format, IDs are written as hex hashes.
+func (resource StringsResource) Export(w io.Writer, format StringsFormat) error {
+	switch format {
+	case StringsFormatJSON:
+		return resource.writeJSON(w)
+	case StringsFormatCSV:
+		return resource.writeCSV(w)
+	default:
+		return fmt.Errorf("unknown strings format %q", format)
+	}
+}
+
+// ImportStrings encodes resource from export of given format. Header fields not
+// stored in export (CSV) are taken from original resource if it's set, byte
+// order is always taken from order.
+func ImportStrings(r io.Reader, format StringsFormat, original []byte, order binary.ByteOrder) ([]byte, error) {
+	var resource *StringsResource
+	var err error
+	switch format {
+	case StringsFormatJSON:
+		resource, err = stringsFromJSON(r)
+	case StringsFormatCSV:
+		resource, err = stringsFromCSV(r)
+		if err == nil && original != nil {
+			header, err := StringsResourceFromBytesWithOrder(original, order)
+			if err != nil {
+				return nil, errors.Join(fmt.Errorf("invalid original resource"), err)
+			}
+			resource.Magic, resource.Version, resource.Language = header.Magic, header.Version, header.Language
+		}
+	default:
+		return nil, fmt.Errorf("unknown strings format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resource.ByteOrder = order
+	return resource.ToBytes()
+}
+
+// stringsDocument is JSON form of strings resource, hashes are hex strings.
+type stringsDocument struct {
+	Magic    string
+	Version  uint32
+	Language string
+	Strings  []stringsDocumentEntry
+}
+
+type stringsDocumentEntry struct {
+	ID    string
+	Value string
+}
+
+var stringsCsvHeader = []string{"ID", "Value"}
+
+func (resource StringsResource) writeJSON(w io.Writer) error {
+	document := stringsDocument{
+		Magic:    formatStringsHash(resource.Magic),
+		Version:  resource.Version,
+		Language: formatStringsHash(resource.Language),
+		Strings:  make([]stringsDocumentEntry, len(resource.Strings)),
+	}
+	for i, entry := range resource.Strings {
+		document.Strings[i] = stringsDocumentEntry{
+			ID:    formatStringsHash(entry.ID),
+			Value: entry.Value,
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
+
+func stringsFromJSON(r io.Reader) (*StringsResource, error) {
+	var document stringsDocument
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+	var resource StringsResource
+	var err error
+	if resource.Magic, err = parseStringsHash(document.Magic); err != nil {
+		return nil, errors.Join(fmt.Errorf("invalid magic"), err)
+	}
+	if resource.Language, err = parseStringsHash(document.Language); err != nil {
+		return nil, errors.Join(fmt.Errorf("invalid language"), err)
+	}
+	resource.Version = document.Version
+	resource.Strings = make([]StringsEntry, len(document.Strings))
+	for i, entry := range document.Strings {
+		id, err := parseStringsHash(entry.ID)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("invalid string ID %q", entry.ID), err)
+		}
+		resource.Strings[i] = StringsEntry{
+			ID:    id,
+			Value: entry.Value,
+		}
+	}
+	return &resource, nil
+}
+
+func (resource StringsResource) writeCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write(stringsCsvHeader)
+	for _, entry := range resource.Strings {
+		writer.Write([]string{formatStringsHash(entry.ID), entry.Value})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// stringsFromCSV reads strings table, header row is optional.
+func stringsFromCSV(r io.Reader) (*StringsResource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(stringsCsvHeader)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 && records[0][0] == stringsCsvHeader[0] {
+		records = records[1:]
+	}
+	resource := StringsResource{
+		Strings: make([]StringsEntry, len(records)),
+	}
+	for i, record := range records {
+		id, err := parseStringsHash(record[0])
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("invalid string ID %q", record[0]), err)
+		}
+		resource.Strings[i] = StringsEntry{
+			ID:    id,
+			Value: record[1],
+		}
+	}
+	return &resource, nil
+}
+
+func formatStringsHash(hash uint32) string {
+	return fmt.Sprintf("%08X", hash)
+}
+
+func parseStringsHash(value string) (uint32, error) {
+	hash, err := strconv.ParseUint(value, 16, 32)
+	return uint32(hash), err
+}
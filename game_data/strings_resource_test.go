@@ -0,0 +1,145 @@
+package game_data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testStrings = StringsResource{
+	Magic:    0x12345678,
+	Version:  1,
+	Language: 0x9abcdef0,
+	Strings: []StringsEntry{
+		{ID: 0x00000001, Value: "Hello"},
+		{ID: 0xdeadbeef, Value: "Grüße, \"world\",\nnew line"},
+		{ID: 0x00000002, Value: ""},
+	},
+}
+
+func TestStringsResourceRoundTrip(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		resource := testStrings
+		resource.ByteOrder = order
+		data, err := resource.ToBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := StringsResourceFromBytesWithOrder(data, order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*got, resource) {
+			t.Errorf("%v: got %+v, want %+v", order, *got, resource)
+		}
+		encoded, err := got.ToBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Errorf("%v: resource is not byte-identical", order)
+		}
+	}
+}
+
+func TestStringsResourceLayout(t *testing.T) {
+	resource := StringsResource{
+		Magic:    1,
+		Version:  2,
+		Language: 3,
+		Strings:  []StringsEntry{{ID: 4, Value: "a"}, {ID: 5, Value: "bc"}},
+	}
+	data, err := resource.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		1, 0, 0, 0, 2, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0,
+		4, 0, 0, 0, 5, 0, 0, 0,
+		32, 0, 0, 0, 34, 0, 0, 0,
+		'a', 0, 'b', 'c', 0,
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("got % X, want % X", data, want)
+	}
+}
+
+func TestStringsResourceInvalid(t *testing.T) {
+	if _, err := (StringsResource{Strings: []StringsEntry{{Value: "a\x00b"}}}).ToBytes(); err == nil {
+		t.Error("string with null character is encoded")
+	}
+
+	valid, err := testStrings.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated header", valid[:stringsHeaderSize-1]},
+		{"truncated tables", valid[:stringsHeaderSize+4]},
+		{"not null-terminated", valid[:len(valid)-1]},
+		{"offset out of bounds", func() []byte {
+			data := bytes.Clone(valid)
+			binary.LittleEndian.PutUint32(data[stringsHeaderSize+len(testStrings.Strings)*4:], uint32(len(data)+1))
+			return data
+		}()},
+		{"invalid UTF-8", func() []byte {
+			data := bytes.Clone(valid)
+			data[bytes.Index(data, []byte("Hello"))] = 0xff
+			return data
+		}()},
+	}
+	for _, test := range tests {
+		if _, err := StringsResourceFromBytes(test.data); err == nil {
+			t.Errorf("%s: resource is decoded", test.name)
+		}
+	}
+}
+
+func TestStringsExportImport(t *testing.T) {
+	for _, format := range []StringsFormat{StringsFormatJSON, StringsFormatCSV} {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			resource := testStrings
+			resource.ByteOrder = order
+			original, err := resource.ToBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			export := new(strings.Builder)
+			if err := resource.Export(export, format); err != nil {
+				t.Fatal(err)
+			}
+			// CSV doesn't hold header fields, they are taken from original
+			data, err := ImportStrings(strings.NewReader(export.String()), format, original, order)
+			if err != nil {
+				t.Fatalf("%s %v: %v", format, order, err)
+			}
+			if !bytes.Equal(data, original) {
+				t.Errorf("%s %v: imported resource differs from original", format, order)
+			}
+		}
+	}
+}
+
+func TestStringsFormatFromPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		format StringsFormat
+		ok     bool
+	}{
+		{"strings/en.strings.json", StringsFormatJSON, true},
+		{"en.strings.CSV", StringsFormatCSV, true},
+		{"en.strings", "", false},
+		{"strings", "", false},
+	}
+	for _, test := range tests {
+		format, ok := StringsFormatFromPath(test.path)
+		if format != test.format || ok != test.ok {
+			t.Errorf("StringsFormatFromPath(%q) = %q, %v, want %q, %v", test.path, format, ok, test.format, test.ok)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package game_data
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Zekfad/hd-tool/hash_db"
+)
+
+var (
+	typeNamesMu sync.RWMutex
+	typeNames   = map[TypeHash]string{}
+	typeHashes  = map[string]TypeHash{}
+)
+
+func init() {
+	for _, name := range []string{
+		"ah_bin",
+		"animation",
+		"bik",
+		"bones",
+		"camera_shake",
+		"cloth",
+		"config",
+		"flow",
+		"entity",
+		"font",
+		"geleta",
+		"geometry_group",
+		"hash_lookup",
+		"havok_ai_properties",
+		"havok_physics_properties",
+		"ik_skeleton",
+		"level",
+		"lua",
+		"material",
+		"mouse_cursor",
+		"network_config",
+		"package",
+		"particles",
+		"physics",
+		"physics_properties",
+		"prefab",
+		"ragdoll_profile",
+		"render_config",
+		"renderable",
+		"runtime_font",
+		"shader_library",
+		"shader_library_group",
+		"shading_environment",
+		"shading_environment_mapping",
+		"speedtree",
+		"state_machine",
+		"strings",
+		"surface_properties",
+		"texture",
+		"texture_atlas",
+		"timpani_bank",
+		"timpani_master",
+		"unit",
+		"vector_field",
+		"wwise_bank",
+		"wwise_dep",
+		"wwise_metadata",
+		"wwise_properties",
+		"wwise_stream",
+	} {
+		RegisterTypeName(name)
+	}
+}
+
+// RegisterTypeName makes type name known, type hash is computed from name.
+func RegisterTypeName(name string) TypeHash {
+	hash := TypeHash(hash_db.Hash(name))
+	typeNamesMu.Lock()
+	defer typeNamesMu.Unlock()
+	typeNames[hash] = name
+	typeHashes[name] = hash
+	return hash
+}
+
+// RegisterTypeNamesFromFile registers type names read by lines from file.
+// Empty lines and comments starting with # are skipped.
+func RegisterTypeNamesFromFile(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return errors.Join(
+			fmt.Errorf("failed to open file"),
+			err,
+		)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		RegisterTypeName(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Join(
+			fmt.Errorf("failed to read type names"),
+			err,
+		)
+	}
+	return nil
+}
+
+// TypeName returns registered name of type.
+func TypeName(hash TypeHash) (string, bool) {
+	typeNamesMu.RLock()
+	defer typeNamesMu.RUnlock()
+	name, ok := typeNames[hash]
+	return name, ok
+}
+
+// TypeFromName returns type hash of registered type name.
+func TypeFromName(name string) (TypeHash, bool) {
+	typeNamesMu.RLock()
+	defer typeNamesMu.RUnlock()
+	hash, ok := typeHashes[name]
+	return hash, ok
+}
+
+// ParseTypeHash parses registered type name or hex type hash.
+func ParseTypeHash(value string) (TypeHash, error) {
+	if hash, ok := TypeFromName(value); ok {
+		return hash, nil
+	}
+	hash, err := strconv.ParseUint(value, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unknown type %s", value)
+	}
+	return TypeHash(hash), nil
+}
+
+// String returns registered type name or hex type hash.
+func (hash TypeHash) String() string {
+	if name, ok := TypeName(hash); ok {
+		return name
+	}
+	return fmt.Sprintf("%016X", uint64(hash))
+}
@@ -0,0 +1,98 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/writer/writer_hd1"
+	"github.com/Zekfad/hd-tool/game_data/writer/writer_hd2"
+)
+
+// CompanionOpener returns writer for companion file with given extension.
+// Encoders call it only for companion files they have data for.
+type CompanionOpener = func(extension string) (io.Writer, error)
+
+// Encoder writes archive main file to writer. If companion is nil, buffers
+// stored in companion files are left untouched and only main file is written.
+type Encoder func(archive game_data.Archive, writer io.Writer, companion CompanionOpener) error
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[game_data.ArchiveVersion]Encoder{}
+)
+
+// built-in writers, same as built-in readers
+func init() {
+	Register(game_data.ArchiveVersionHD1, writer_hd1.Encode)
+	Register(game_data.ArchiveVersionHD2, writer_hd2.Encode)
+}
+
+// Register makes encoder available for archives of given version.
+// HD1 and HD2 writers are registered by this package, other writers register
+// themselves on import. Registering the same version twice panics.
+func Register(version game_data.ArchiveVersion, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	if encoder == nil {
+		panic("writer: Register encoder is nil")
+	}
+	if _, dup := encoders[version]; dup {
+		panic(fmt.Sprintf("writer: Register called twice for version %#08X", uint32(version)))
+	}
+	encoders[version] = encoder
+}
+
+// Lookup returns encoder registered for given version.
+func Lookup(version game_data.ArchiveVersion) (Encoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	encoder, ok := encoders[version]
+	return encoder, ok
+}
+
+func WriteArchive(archive game_data.Archive, writer io.Writer, companion CompanionOpener) error {
+	encoder, ok := Lookup(archive.GetVersion())
+	if !ok {
+		return fmt.Errorf("failed to encode archive of version %#X, writing it is not supported", uint32(archive.GetVersion()))
+	}
+	return encoder(archive, writer, companion)
+}
+
+// WriteArchiveFile writes archive to file, if withCompanions is set companion
+// files are written next to it.
+func WriteArchiveFile(archive game_data.Archive, name string, withCompanions bool) error {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return errors.Join(
+			fmt.Errorf("failed to open file"),
+			err,
+		)
+	}
+	defer file.Close()
+
+	if !withCompanions {
+		return WriteArchive(archive, file, nil)
+	}
+
+	var companions []*os.File
+	defer func() {
+		for _, companion := range companions {
+			companion.Close()
+		}
+	}()
+	return WriteArchive(archive, file, func(extension string) (io.Writer, error) {
+		companion, err := os.OpenFile(name+extension, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+		if err != nil {
+			return nil, errors.Join(
+				fmt.Errorf("failed to open companion file %s", extension),
+				err,
+			)
+		}
+		companions = append(companions, companion)
+		return companion, nil
+	})
+}
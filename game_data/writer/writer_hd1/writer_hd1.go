@@ -4,34 +4,152 @@ import (
 	"bytes"
 	"compress/zlib"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"slices"
 
+	"github.com/Zekfad/hd-tool/game_data"
 	"github.com/Zekfad/hd-tool/game_data/hd1"
 )
 
-var le = binary.LittleEndian
+// Encode writes archive, companion opens .stream file writer. It's registered
+// as writer package encoder of HD1 versions.
+func Encode(archive game_data.Archive, w io.Writer, companion func(extension string) (io.Writer, error)) error {
+	var hd1Archive hd1.Archive
+	switch archive := archive.(type) {
+	case *hd1.Archive:
+		hd1Archive = *archive
+	default:
+		return fmt.Errorf("unsupported archive type %T", archive)
+	}
+	if err := checkVersion(hd1Archive); err != nil {
+		return err
+	}
+	if companion == nil {
+		return WriteArchive(hd1Archive, w)
+	}
 
-func WriteArchive(archive hd1.Archive, writer io.Writer) error {
-	binary.Write(writer, le, uint32(archive.ArchiveVersion))
+	hd1Archive.Unpacked.Files = slices.Clone(hd1Archive.Unpacked.Files)
+	streamSize, err := LayoutStreams(&hd1Archive)
+	if err != nil {
+		return err
+	}
+	var streamWriter io.Writer = io.Discard
+	if streamSize > 0 {
+		if streamWriter, err = companion(hd1.StreamExtension); err != nil {
+			return err
+		}
+	}
+	if err := WriteArchive(hd1Archive, w); err != nil {
+		return err
+	}
+	return writeStreams(hd1Archive, streamWriter)
+}
+
+// WriteArchiveWithStreams writes archive along with its .stream companion file.
+func WriteArchiveWithStreams(archive hd1.Archive, writer io.Writer, streamWriter io.Writer) error {
+	if err := checkVersion(archive); err != nil {
+		return err
+	}
+	archive.Unpacked.Files = slices.Clone(archive.Unpacked.Files)
+	if _, err := LayoutStreams(&archive); err != nil {
+		return err
+	}
+	if err := WriteArchive(archive, writer); err != nil {
+		return err
+	}
+	return writeStreams(archive, streamWriter)
+}
+
+// checkVersion rejects archives of other versions sharing HD1 layout, their
+// support is read-only, see [hd1.HasTypeFlags].
+func checkVersion(archive hd1.Archive) error {
+	if archive.ArchiveVersion != game_data.ArchiveVersionHD1 {
+		return fmt.Errorf("writing archives of version %#08X is not supported", uint32(archive.ArchiveVersion))
+	}
+	return nil
+}
+
+// LayoutStreams recalculates stream sizes and offsets in place and returns size
+// of stream file. Stream buffers of a file are packed one after another, files
+// keep existing offsets as long as they don't overlap preceding buffers.
+// Lazily loaded buffers are read into memory, it's an error if they are not
+// available (e.g. stream file is missing).
+func LayoutStreams(archive *hd1.Archive) (uint64, error) {
+	var cursor uint64
+	for i := range archive.Unpacked.Files {
+		file := &archive.Unpacked.Files[i]
+		streams, err := file.ReadVariantStreams()
+		if err != nil {
+			return 0, errors.Join(fmt.Errorf("failed to read stream buffers of file %016X.%s", file.Name, file.Type), err)
+		}
+		if streams == nil {
+			continue
+		}
+		file.VariantStreamBuffers = streams
+		file.VariantHeaders = slices.Clone(file.VariantHeaders)
+		var size uint64
+		for j, stream := range streams {
+			file.VariantHeaders[j].StreamSize = uint32(len(stream))
+			size += uint64(len(stream))
+		}
+		if size == 0 {
+			continue
+		}
+		if uint64(file.StreamOffset) < cursor {
+			file.StreamOffset = uint32(cursor)
+		}
+		cursor = uint64(file.StreamOffset) + size
+	}
+	return cursor, nil
+}
 
+func writeStreams(archive hd1.Archive, writer io.Writer) error {
+	var cursor uint64
+	for _, file := range archive.Unpacked.Files {
+		if file.StreamSize() == 0 || file.VariantStreamBuffers == nil {
+			continue
+		}
+		if _, err := writer.Write(make([]byte, uint64(file.StreamOffset)-cursor)); err != nil {
+			return err
+		}
+		for _, stream := range file.VariantStreamBuffers {
+			if _, err := writer.Write(stream); err != nil {
+				return err
+			}
+		}
+		cursor = uint64(file.StreamOffset) + uint64(file.StreamSize())
+	}
+	return nil
+}
+
+// WriteArchive writes archive in its byte order, stream offsets and sizes are
+// kept as is. Original chunks of archive holding unchanged data are written as
+// is instead of being compressed again, see [hd1.PackedArchiveFromReaderAt].
+func WriteArchive(archive hd1.Archive, writer io.Writer) error {
+	if err := checkVersion(archive); err != nil {
+		return err
+	}
+	order := archive.GetByteOrder()
 	buffer := new(bytes.Buffer)
-	binary.Write(buffer, le, archive.Unpacked.Header.EntriesCount)
+	binary.Write(buffer, order, archive.Unpacked.Header.EntriesCount)
 	buffer.Write(archive.Unpacked.Header.Magic)
 	for _, _type := range archive.Unpacked.Types {
-		binary.Write(buffer, le, uint64(_type.Type))
-		binary.Write(buffer, le, uint64(_type.Name))
+		binary.Write(buffer, order, uint64(_type.Type))
+		binary.Write(buffer, order, uint64(_type.Name))
 	}
 	for _, file := range archive.Unpacked.Files {
-		binary.Write(buffer, le, uint64(file.Type))
-		binary.Write(buffer, le, uint64(file.Name))
-		binary.Write(buffer, le, file.VariantsCount)
-		binary.Write(buffer, le, file.StreamOffset)
+		binary.Write(buffer, order, uint64(file.Type))
+		binary.Write(buffer, order, uint64(file.Name))
+		binary.Write(buffer, order, file.VariantsCount)
+		binary.Write(buffer, order, file.StreamOffset)
 
 		for i, header := range file.VariantHeaders {
-			binary.Write(buffer, le, header.Unk00)
-			// binary.Write(buffer, le, header.Size)
-			binary.Write(buffer, le, uint32(len(file.VariantBuffers[i])))
-			binary.Write(buffer, le, header.StreamSize)
+			binary.Write(buffer, order, header.Unk00)
+			// binary.Write(buffer, order, header.Size)
+			binary.Write(buffer, order, uint32(len(file.VariantBuffers[i])))
+			binary.Write(buffer, order, header.StreamSize)
 		}
 		for _, variantBuffer := range file.VariantBuffers {
 			buffer.Write(variantBuffer)
@@ -40,20 +158,73 @@ func WriteArchive(archive hd1.Archive, writer io.Writer) error {
 
 	dataRaw := buffer.Bytes()
 
-	binary.Write(writer, le, uint32(len(dataRaw)))
-	binary.Write(writer, le, uint32(0))
+	chunks, reused := packChunks(dataRaw, archive.Chunks)
+	unpackedSize, reserved := uint32(len(dataRaw)), uint32(0)
+	// unchanged archive keeps its header
+	if reused == len(chunks) && len(chunks) == len(archive.Chunks) {
+		unpackedSize, reserved = archive.UnpackedSize, archive.Reserved
+	}
+	header := hd1.PackedHeader{
+		ArchiveVersion: archive.ArchiveVersion,
+		UnpackedSize:   unpackedSize,
+		Reserved:       reserved,
+	}
+	if err := binary.Write(writer, order, header); err != nil {
+		return err
+	}
 
-	for i := 0; i < len(dataRaw); i += hd1.CompressedChunkSize {
-		part := dataRaw[i : i+hd1.CompressedChunkSize]
+	for _, chunk := range chunks {
+		if err := binary.Write(writer, order, chunk.Size); err != nil {
+			return err
+		}
+		if _, err := writer.Write(chunk.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packChunks splits unpacked data into chunks and returns them along with
+// number of original chunks kept. Original chunk holding the same data is kept
+// as is, so that unchanged data is written byte to byte, otherwise chunk is
+// compressed.
+func packChunks(data []byte, original []hd1.PackedChunk) ([]hd1.PackedChunk, int) {
+	var chunks []hd1.PackedChunk
+	var reused int
+	for i := 0; i < len(data); i += hd1.CompressedChunkSize {
+		part := data[i:min(i+hd1.CompressedChunkSize, len(data))]
+		if n := len(chunks); n < len(original) && isChunkOf(original[n], part) {
+			chunks = append(chunks, original[n])
+			reused++
+			continue
+		}
+
+		// last chunk is padded to full chunk size
+		padded := make([]byte, hd1.CompressedChunkSize)
+		copy(padded, part)
 		b := new(bytes.Buffer)
 		z := zlib.NewWriter(b)
-		z.Write(part)
+		z.Write(padded)
 		z.Close()
 
 		compressed := b.Bytes()
+		// chunk of exactly chunk size is stored uncompressed
+		if len(compressed) >= hd1.CompressedChunkSize {
+			compressed = padded
+		}
+		chunks = append(chunks, hd1.PackedChunk{
+			Size: uint32(len(compressed)),
+			Data: compressed,
+		})
+	}
+	return chunks, reused
+}
 
-		binary.Write(writer, le, uint32(len(compressed)))
-		writer.Write(compressed)
+// isChunkOf reports whether chunk holds data followed by zero padding only.
+func isChunkOf(chunk hd1.PackedChunk, data []byte) bool {
+	inflated, err := chunk.Inflate()
+	if err != nil || len(inflated) < len(data) || !bytes.Equal(inflated[:len(data)], data) {
+		return false
 	}
-	return nil
+	return !slices.ContainsFunc(inflated[len(data):], func(b byte) bool { return b != 0 })
 }
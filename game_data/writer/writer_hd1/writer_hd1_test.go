@@ -0,0 +1,221 @@
+package writer_hd1
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd1"
+)
+
+const testType game_data.TypeHash = 0xa14e8dfa2cd117e2
+
+// testArchive returns archive of several files, one of them spans chunks and
+// one has variants with stream buffers.
+func testArchive(t *testing.T, version game_data.ArchiveVersion, order binary.ByteOrder) *hd1.Archive {
+	archive := hd1.NewArchive()
+	archive.ArchiveVersion = version
+	archive.ByteOrder = order
+	files := []struct {
+		name game_data.NameHash
+		data game_data.FileData
+	}{
+		{1, game_data.FileData{Inline: []byte("small")}},
+		{2, game_data.FileData{Inline: bytes.Repeat([]byte("large"), hd1.CompressedChunkSize/2)}},
+		{3, game_data.FileData{
+			Variants:       [][]byte{[]byte("en"), []byte("de")},
+			VariantStreams: [][]byte{[]byte("stream en"), []byte("stream de")},
+		}},
+		{4, game_data.FileData{Stream: []byte("stream")}},
+	}
+	for _, file := range files {
+		if err := archive.AddFile(file.name, testType, file.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return archive
+}
+
+func write(t *testing.T, archive *hd1.Archive) ([]byte, []byte) {
+	data, stream := new(bytes.Buffer), new(bytes.Buffer)
+	if err := WriteArchiveWithStreams(*archive, data, stream); err != nil {
+		t.Fatal(err)
+	}
+	return data.Bytes(), stream.Bytes()
+}
+
+func read(t *testing.T, data []byte, stream []byte) *hd1.Archive {
+	archive, err := hd1.ArchiveFromReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive.SetStreamSource(bytes.NewReader(stream))
+	return archive
+}
+
+func TestWriteArchiveRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		version game_data.ArchiveVersion
+		order   binary.ByteOrder
+	}{
+		{"HD1", game_data.ArchiveVersionHD1, binary.LittleEndian},
+		{"HD1 big-endian", game_data.ArchiveVersionHD1, binary.BigEndian},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive := testArchive(t, test.version, test.order)
+			data, stream := write(t, archive)
+			got := read(t, data, stream)
+
+			if got.ArchiveVersion != test.version || got.GetByteOrder() != test.order {
+				t.Fatalf("got version %#08X in %v", got.ArchiveVersion, got.GetByteOrder())
+			}
+			if !reflect.DeepEqual(got.Unpacked.Types, archive.Unpacked.Types) {
+				t.Fatalf("got types %+v, want %+v", got.Unpacked.Types, archive.Unpacked.Types)
+			}
+			if len(got.Unpacked.Files) != len(archive.Unpacked.Files) {
+				t.Fatalf("got %d files, want %d", len(got.Unpacked.Files), len(archive.Unpacked.Files))
+			}
+			for i, file := range archive.Unpacked.Files {
+				gotFile := got.Unpacked.Files[i]
+				if gotFile.Name != file.Name || gotFile.Type != file.Type {
+					t.Errorf("file %d: got %016X.%s, want %016X.%s", i, gotFile.Name, gotFile.Type, file.Name, file.Type)
+				}
+				if !reflect.DeepEqual(gotFile.VariantHeaders, file.VariantHeaders) {
+					t.Errorf("file %d: got variants %+v, want %+v", i, gotFile.VariantHeaders, file.VariantHeaders)
+				}
+				if !reflect.DeepEqual(gotFile.VariantBuffers, file.VariantBuffers) {
+					t.Errorf("file %d: variant buffers differ", i)
+				}
+				stream, err := gotFile.ReadStreamBuffer()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := bytes.Join(file.VariantStreamBuffers, nil); !bytes.Equal(stream, want) {
+					t.Errorf("file %d: got stream %q, want %q", i, stream, want)
+				}
+			}
+		})
+	}
+}
+
+// TestWriteArchiveReadOnlyVersion checks that archives with experimental HD1
+// layout are not written.
+func TestWriteArchiveReadOnlyVersion(t *testing.T) {
+	archive := testArchive(t, game_data.ArchiveVersionVT2, binary.LittleEndian)
+	data, stream := new(bytes.Buffer), new(bytes.Buffer)
+	if err := WriteArchiveWithStreams(*archive, data, stream); err == nil {
+		t.Error("VT2 archive is written")
+	}
+	if data.Len() != 0 || stream.Len() != 0 {
+		t.Error("VT2 archive is partially written")
+	}
+}
+
+// TestWriteArchiveByteIdentical checks that unchanged archive compressed by
+// other zlib implementation is written byte to byte.
+func TestWriteArchiveByteIdentical(t *testing.T) {
+	data, stream := write(t, testArchive(t, game_data.ArchiveVersionHD1, binary.LittleEndian))
+	original := recompress(t, data, zlib.BestSpeed)
+	if bytes.Equal(original, data) {
+		t.Fatal("recompressed archive is the same")
+	}
+
+	rewritten, rewrittenStream := write(t, read(t, original, stream))
+	if !bytes.Equal(rewritten, original) {
+		t.Error("unchanged archive is not byte-identical")
+	}
+	if !bytes.Equal(rewrittenStream, stream) {
+		t.Error("unchanged stream file is not byte-identical")
+	}
+}
+
+// TestWriteArchiveReusesChunks checks that only chunks with changed data are
+// compressed again.
+func TestWriteArchiveReusesChunks(t *testing.T) {
+	data, stream := write(t, testArchive(t, game_data.ArchiveVersionHD1, binary.LittleEndian))
+	original := read(t, recompress(t, data, zlib.BestSpeed), stream)
+
+	mutable := original.Mutable()
+	if err := mutable.ReplaceFile(4, testType, game_data.FileData{Inline: []byte("changed")}); err != nil {
+		t.Fatal(err)
+	}
+	changed, _ := write(t, mutable.(*hd1.Archive))
+	packed, err := hd1.PackedArchiveFromReaderAt(bytes.NewReader(changed), int64(len(changed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packed.Chunks) != len(original.Chunks) {
+		t.Fatalf("got %d chunks, want %d", len(packed.Chunks), len(original.Chunks))
+	}
+	last := len(packed.Chunks) - 1
+	for i, chunk := range packed.Chunks[:last] {
+		if !bytes.Equal(chunk.Data, original.Chunks[i].Data) {
+			t.Errorf("unchanged chunk %d is not reused", i)
+		}
+	}
+	if bytes.Equal(packed.Chunks[last].Data, original.Chunks[last].Data) {
+		t.Error("changed chunk is reused")
+	}
+	if got := read(t, changed, nil).Unpacked.Files[3].GetInlineBuffer(); string(got) != "changed" {
+		t.Errorf("got changed buffer %q", got)
+	}
+}
+
+// TestWriteArchiveWriteError checks that every failed write is reported.
+func TestWriteArchiveWriteError(t *testing.T) {
+	archive := testArchive(t, game_data.ArchiveVersionHD1, binary.LittleEndian)
+	counter := &failingWriter{fail: -1}
+	if err := WriteArchive(*archive, counter); err != nil {
+		t.Fatal(err)
+	}
+	for i := range counter.calls {
+		if err := WriteArchive(*archive, &failingWriter{fail: i}); err == nil {
+			t.Errorf("failed write %d of %d is not reported", i, counter.calls)
+		}
+	}
+}
+
+// recompress compresses chunks of archive with given level.
+func recompress(t *testing.T, data []byte, level int) []byte {
+	archive, err := hd1.PackedArchiveFromReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := archive.GetByteOrder()
+	b := new(bytes.Buffer)
+	binary.Write(b, order, archive.PackedHeader)
+	for _, chunk := range archive.Chunks {
+		inflated, err := chunk.Inflate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed := new(bytes.Buffer)
+		z, _ := zlib.NewWriterLevel(compressed, level)
+		z.Write(inflated)
+		z.Close()
+		binary.Write(b, order, uint32(compressed.Len()))
+		b.Write(compressed.Bytes())
+	}
+	return b.Bytes()
+}
+
+// failingWriter fails only write call of given index, so that each unchecked
+// write is detected.
+type failingWriter struct {
+	fail  int
+	calls int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.calls++
+	if w.calls-1 == w.fail {
+		return 0, errors.New("write failed")
+	}
+	return len(p), nil
+}
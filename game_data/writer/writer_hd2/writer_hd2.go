@@ -0,0 +1,258 @@
+package writer_hd2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
+)
+
+var le = binary.LittleEndian
+
+// Encode writes archive, companion opens .stream and .gpu_resources file
+// writers. It's registered as writer package encoder of HD2 version.
+func Encode(archive game_data.Archive, w io.Writer, companion func(extension string) (io.Writer, error)) error {
+	var hd2Archive hd2.Archive
+	switch archive := archive.(type) {
+	case *hd2.Archive:
+		hd2Archive = *archive
+	default:
+		return fmt.Errorf("unsupported archive type %T", archive)
+	}
+	if companion == nil {
+		return WriteArchive(hd2Archive, w)
+	}
+
+	hd2Archive, err := prepare(hd2Archive, true)
+	if err != nil {
+		return err
+	}
+	var streamSize uint64
+	for _, file := range hd2Archive.Files {
+		streamSize += uint64(file.StreamSize)
+	}
+	var streamWriter, gpuWriter io.Writer = io.Discard, io.Discard
+	if streamSize > 0 {
+		if streamWriter, err = companion(hd2.StreamExtension); err != nil {
+			return err
+		}
+	}
+	if hd2Archive.Header.GpuBufferSize > 0 {
+		if gpuWriter, err = companion(hd2.GpuResourcesExtension); err != nil {
+			return err
+		}
+	}
+	if err := writeMain(hd2Archive, w); err != nil {
+		return err
+	}
+	return writeStreams(hd2Archive, streamWriter, gpuWriter)
+}
+
+// WriteArchive writes main archive file, stream and gpu resources offsets are
+// kept as is.
+func WriteArchive(archive hd2.Archive, writer io.Writer) error {
+	archive, err := prepare(archive, false)
+	if err != nil {
+		return err
+	}
+	return writeMain(archive, writer)
+}
+
+// WriteArchiveWithStreams writes main archive file along with its .stream and
+// .gpu_resources companion files.
+func WriteArchiveWithStreams(
+	archive hd2.Archive,
+	writer io.Writer,
+	streamWriter io.Writer,
+	gpuWriter io.Writer,
+) error {
+	archive, err := prepare(archive, true)
+	if err != nil {
+		return err
+	}
+	if err := writeMain(archive, writer); err != nil {
+		return err
+	}
+	return writeStreams(archive, streamWriter, gpuWriter)
+}
+
+// prepare lays out a copy of archive, so that caller's archive is not modified.
+func prepare(archive hd2.Archive, streams bool) (hd2.Archive, error) {
+	archive.Types = slices.Clone(archive.Types)
+	archive.Files = slices.Clone(archive.Files)
+	if err := Layout(&archive); err != nil {
+		return hd2.Archive{}, err
+	}
+	if streams {
+		if err := LayoutStreams(&archive); err != nil {
+			return hd2.Archive{}, err
+		}
+	}
+	return archive, nil
+}
+
+func writeStreams(archive hd2.Archive, streamWriter io.Writer, gpuWriter io.Writer) error {
+	var cursor uint64
+	for _, file := range archive.Files {
+		if file.StreamSize == 0 {
+			continue
+		}
+		if _, err := streamWriter.Write(make([]byte, file.StreamOffset-cursor)); err != nil {
+			return err
+		}
+		if _, err := streamWriter.Write(file.StreamBuffer); err != nil {
+			return err
+		}
+		cursor = file.StreamOffset + uint64(file.StreamSize)
+	}
+
+	cursor = 0
+	for _, file := range archive.Files {
+		if file.GpuStreamSize == 0 {
+			continue
+		}
+		if _, err := gpuWriter.Write(make([]byte, file.GpuOffset-cursor)); err != nil {
+			return err
+		}
+		if _, err := gpuWriter.Write(file.GpuBuffer); err != nil {
+			return err
+		}
+		cursor = file.GpuOffset + uint64(file.GpuStreamSize)
+	}
+	if cursor == 0 {
+		return nil
+	}
+	_, err := gpuWriter.Write(make([]byte, archive.Header.GpuBufferSize-cursor))
+	return err
+}
+
+func writeMain(archive hd2.Archive, writer io.Writer) error {
+	// tables are encoded in memory and written at once
+	tables := new(bytes.Buffer)
+	binary.Write(tables, le, archive.Header)
+	for _, _type := range archive.Types {
+		binary.Write(tables, le, _type)
+	}
+	for _, file := range archive.Files {
+		binary.Write(tables, le, file.Name)
+		binary.Write(tables, le, uint64(file.Type))
+		binary.Write(tables, le, file.Offset)
+		binary.Write(tables, le, file.StreamOffset)
+		binary.Write(tables, le, file.GpuOffset)
+		binary.Write(tables, le, file.BufferOffset)
+		binary.Write(tables, le, file.GpuBufferOffset)
+		binary.Write(tables, le, file.Size)
+		binary.Write(tables, le, file.StreamSize)
+		binary.Write(tables, le, file.GpuStreamSize)
+		binary.Write(tables, le, file.Alignment)
+		binary.Write(tables, le, file.GpuAlignment)
+		binary.Write(tables, le, file.Index)
+	}
+	if _, err := writer.Write(tables.Bytes()); err != nil {
+		return err
+	}
+
+	cursor := archive.TablesSize()
+	for _, file := range archive.Files {
+		if file.Size == 0 {
+			continue
+		}
+		if _, err := writer.Write(make([]byte, file.Offset-cursor)); err != nil {
+			return err
+		}
+		if _, err := writer.Write(file.InlineBuffer); err != nil {
+			return err
+		}
+		cursor = file.Offset + uint64(file.Size)
+	}
+	_, err := writer.Write(make([]byte, archive.Header.BufferSize-cursor))
+	return err
+}
+
+// Layout recalculates counts, sizes and offsets of inline buffers in place.
+// Inline buffers are placed in file table order, each one aligned to its
+// file alignment. Existing offsets are kept as long as they are aligned and
+// don't overlap preceding buffers, so unchanged archive is written as is.
+// Lazily loaded buffers are read into memory.
+func Layout(archive *hd2.Archive) error {
+	archive.Header.TypesCount = uint32(len(archive.Types))
+	archive.Header.FilesCount = uint32(len(archive.Files))
+
+	cursor := archive.TablesSize()
+	for i := range archive.Files {
+		file := &archive.Files[i]
+		buffer, err := file.ReadInlineBuffer()
+		if err != nil {
+			return err
+		}
+		file.InlineBuffer = buffer
+		file.Size = uint32(len(file.InlineBuffer))
+		if file.Size == 0 {
+			continue
+		}
+		file.Offset = place(file.Offset, cursor, uint64(file.Alignment))
+		cursor = file.Offset + uint64(file.Size)
+	}
+	archive.Header.BufferSize = max(archive.Header.BufferSize, cursor)
+	return nil
+}
+
+// LayoutStreams recalculates sizes and offsets of stream and gpu buffers in
+// place. Stream buffers are packed without alignment, gpu buffers are aligned
+// to file gpu alignment. Existing offsets are kept same way as in [Layout].
+// Lazily loaded buffers are read into memory.
+func LayoutStreams(archive *hd2.Archive) error {
+	var streamCursor, gpuCursor uint64
+	for i := range archive.Files {
+		file := &archive.Files[i]
+		stream, err := file.ReadStreamBuffer()
+		if err != nil {
+			return err
+		}
+		gpu, err := file.ReadGpuBuffer()
+		if err != nil {
+			return err
+		}
+		file.StreamBuffer = stream
+		file.GpuBuffer = gpu
+
+		file.StreamSize = uint32(len(file.StreamBuffer))
+		if file.StreamSize != 0 {
+			file.StreamOffset = place(file.StreamOffset, streamCursor, 1)
+			streamCursor = file.StreamOffset + uint64(file.StreamSize)
+		}
+
+		file.GpuStreamSize = uint32(len(file.GpuBuffer))
+		if file.GpuStreamSize != 0 {
+			file.GpuOffset = place(file.GpuOffset, gpuCursor, uint64(file.GpuAlignment))
+			gpuCursor = file.GpuOffset + uint64(file.GpuStreamSize)
+		}
+	}
+	if gpuCursor == 0 {
+		archive.Header.GpuBufferSize = 0
+	} else {
+		archive.Header.GpuBufferSize = max(archive.Header.GpuBufferSize, gpuCursor)
+	}
+	return nil
+}
+
+// place returns offset of a buffer: existing offset is kept if it is aligned
+// and doesn't overlap preceding buffers, otherwise buffer is placed right after
+// them.
+func place(offset uint64, cursor uint64, alignment uint64) uint64 {
+	if offset >= cursor && (alignment <= 1 || offset%alignment == 0) {
+		return offset
+	}
+	return align(cursor, alignment)
+}
+
+func align(offset uint64, alignment uint64) uint64 {
+	if alignment <= 1 {
+		return offset
+	}
+	return (offset + alignment - 1) / alignment * alignment
+}
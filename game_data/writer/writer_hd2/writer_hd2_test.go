@@ -0,0 +1,157 @@
+package writer_hd2
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Zekfad/hd-tool/game_data"
+	"github.com/Zekfad/hd-tool/game_data/hd2"
+)
+
+const (
+	testType    game_data.TypeHash = 0xa14e8dfa2cd117e2
+	testGpuType game_data.TypeHash = 0xcd4238c6a0c69e32
+)
+
+var testFiles = []struct {
+	name  game_data.NameHash
+	_type game_data.TypeHash
+	data  game_data.FileData
+}{
+	{1, testType, game_data.FileData{Inline: []byte("small")}},
+	{2, testType, game_data.FileData{Inline: []byte("odd"), Stream: []byte("stream")}},
+	{3, testGpuType, game_data.FileData{Inline: []byte("texture"), Stream: []byte("mip"), Gpu: []byte("gpu")}},
+	{4, testType, game_data.FileData{}},
+}
+
+func testArchive(t *testing.T) *hd2.Archive {
+	archive := hd2.NewArchive()
+	for _, file := range testFiles {
+		if err := archive.AddFile(file.name, file._type, file.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return archive
+}
+
+type output struct {
+	data, stream, gpu []byte
+}
+
+func write(t *testing.T, archive *hd2.Archive) output {
+	data, stream, gpu := new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer)
+	if err := WriteArchiveWithStreams(*archive, data, stream, gpu); err != nil {
+		t.Fatal(err)
+	}
+	return output{data.Bytes(), stream.Bytes(), gpu.Bytes()}
+}
+
+func read(t *testing.T, out output) *hd2.Archive {
+	archive, err := hd2.ArchiveFromReaderAt(bytes.NewReader(out.data), int64(len(out.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive.SetStreamSource(bytes.NewReader(out.stream))
+	archive.SetGpuSource(bytes.NewReader(out.gpu))
+	return archive
+}
+
+func TestWriteArchiveRoundTrip(t *testing.T) {
+	archive := read(t, write(t, testArchive(t)))
+
+	if archive.Header.ArchiveVersion != game_data.ArchiveVersionHD2 {
+		t.Fatalf("got version %#08X", archive.Header.ArchiveVersion)
+	}
+	if len(archive.Types) != 2 || archive.Types[0].Count != 3 || archive.Types[1].Count != 1 {
+		t.Fatalf("got types %+v", archive.Types)
+	}
+	if len(archive.Files) != len(testFiles) {
+		t.Fatalf("got %d files, want %d", len(archive.Files), len(testFiles))
+	}
+	for i, want := range testFiles {
+		file := archive.Files[i]
+		if file.Name != want.name || file.Type != want._type {
+			t.Errorf("file %d: got %016X.%s, want %016X.%s", i, file.Name, file.Type, want.name, want._type)
+		}
+		if file.Offset%uint64(file.Alignment) != 0 || file.GpuOffset%uint64(file.GpuAlignment) != 0 {
+			t.Errorf("file %d: offsets %#X and %#X are not aligned", i, file.Offset, file.GpuOffset)
+		}
+		buffers := []struct {
+			name string
+			read func() ([]byte, error)
+			want []byte
+		}{
+			{"inline", file.ReadInlineBuffer, want.data.Inline},
+			{"stream", file.ReadStreamBuffer, want.data.Stream},
+			{"gpu", file.ReadGpuBuffer, want.data.Gpu},
+		}
+		for _, buffer := range buffers {
+			got, err := buffer.read()
+			if err != nil {
+				t.Fatalf("file %d: %s buffer: %v", i, buffer.name, err)
+			}
+			if !bytes.Equal(got, buffer.want) {
+				t.Errorf("file %d: got %s buffer %q, want %q", i, buffer.name, got, buffer.want)
+			}
+		}
+	}
+}
+
+// TestWriteArchiveByteIdentical checks that archive read back is written byte
+// to byte, including companion files.
+func TestWriteArchiveByteIdentical(t *testing.T) {
+	written := write(t, testArchive(t))
+	rewritten := write(t, read(t, written))
+	if !reflect.DeepEqual(rewritten, written) {
+		t.Error("unchanged archive is not byte-identical")
+	}
+}
+
+func TestWriteArchiveReplaceFile(t *testing.T) {
+	mutable := read(t, write(t, testArchive(t))).Mutable()
+	changed := bytes.Repeat([]byte("changed"), 100)
+	if err := mutable.ReplaceFile(1, testType, game_data.FileData{Inline: changed}); err != nil {
+		t.Fatal(err)
+	}
+	archive := read(t, write(t, mutable.(*hd2.Archive)))
+	for i, file := range archive.Files {
+		want := testFiles[i].data.Inline
+		if i == 0 {
+			want = changed
+		}
+		if got, err := file.ReadInlineBuffer(); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("file %d: got inline buffer %q (%v), want %q", i, got, err, want)
+		}
+	}
+}
+
+// TestWriteArchiveWriteError checks that every failed write is reported.
+func TestWriteArchiveWriteError(t *testing.T) {
+	archive := testArchive(t)
+	counter := &failingWriter{fail: -1}
+	if err := WriteArchive(*archive, counter); err != nil {
+		t.Fatal(err)
+	}
+	for i := range counter.calls {
+		if err := WriteArchive(*archive, &failingWriter{fail: i}); err == nil {
+			t.Errorf("failed write %d of %d is not reported", i, counter.calls)
+		}
+	}
+}
+
+// failingWriter fails only write call of given index, so that each unchecked
+// write is detected.
+type failingWriter struct {
+	fail  int
+	calls int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.calls++
+	if w.calls-1 == w.fail {
+		return 0, errors.New("write failed")
+	}
+	return len(p), nil
+}
@@ -0,0 +1,62 @@
+package glob
+
+import (
+	"path"
+	"strings"
+)
+
+// Match reports whether slash separated name matches pattern.
+// Pattern segments use path.Match syntax, additionally "**" segment matches
+// zero or more segments.
+func Match(pattern string, name string) (bool, error) {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// MatchAny reports whether name matches any of patterns.
+func MatchAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := Match(pattern, name)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// Validate checks pattern syntax.
+func Validate(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchSegments(pattern []string, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				matched, err := matchSegments(pattern, name[i:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		matched, err := path.Match(pattern[0], name[0])
+		if err != nil || !matched {
+			return false, err
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0, nil
+}
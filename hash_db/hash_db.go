@@ -68,7 +68,7 @@ func FromFile(name string, checkHashes bool) (HashDB, error) {
 }
 
 func (db HashDB) SaveToFile(name string, sortKeys bool) error {
-	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC, 0666)
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
 	if err != nil {
 		return errors.Join(
 			fmt.Errorf("failed to open file"),
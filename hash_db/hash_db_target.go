@@ -43,7 +43,7 @@ func TargetFromFile(name string) (HashDBTarget, error) {
 }
 
 func (target HashDBTarget) SaveToFile(name string, sortKeys bool) error {
-	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC, 0666)
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
 	if err != nil {
 		return errors.Join(
 			fmt.Errorf("failed to open file"),
@@ -0,0 +1,69 @@
+package pool
+
+import (
+	"iter"
+	"runtime"
+)
+
+// Jobs returns number of workers to use, non-positive value means number of
+// CPUs.
+func Jobs(jobs int) int {
+	if jobs < 1 {
+		return runtime.NumCPU()
+	}
+	return jobs
+}
+
+// Map runs work for items using up to jobs goroutines and yields results in
+// items order. At most jobs results are computed ahead of the consumer.
+// If the consumer stops early, results that were already computed but not
+// yielded are passed to release (if it's not nil).
+func Map[T any, R any](
+	items []T,
+	jobs int,
+	work func(item T) R,
+	release func(result R),
+) iter.Seq2[T, R] {
+	return func(yield func(item T, result R) bool) {
+		jobs = Jobs(jobs)
+		results := make([]chan R, len(items))
+		for i := range results {
+			results[i] = make(chan R, 1)
+		}
+
+		slots := make(chan struct{}, jobs)
+		stop := make(chan struct{})
+		dispatched := make(chan int, 1)
+		go func() {
+			for i, item := range items {
+				select {
+				case slots <- struct{}{}:
+				case <-stop:
+					dispatched <- i
+					return
+				}
+				go func() {
+					results[i] <- work(item)
+				}()
+			}
+			dispatched <- len(items)
+		}()
+
+		for i, item := range items {
+			result := <-results[i]
+			<-slots
+			if !yield(item, result) {
+				close(stop)
+				count := <-dispatched
+				for j := i + 1; j < count; j++ {
+					result := <-results[j]
+					if release != nil {
+						release(result)
+					}
+				}
+				return
+			}
+		}
+		<-dispatched
+	}
+}